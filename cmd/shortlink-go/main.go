@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	_ "time/tzdata" // Time series accept any IANA time zone, even on images without zoneinfo.
+
+	"shortlink-go/config"
+	_ "shortlink-go/docs"
+	"shortlink-go/internal/analytics"
+	"shortlink-go/internal/auth"
+	"shortlink-go/internal/cache"
+	"shortlink-go/internal/counter"
+	"shortlink-go/internal/database"
+	"shortlink-go/internal/handler"
+	"shortlink-go/internal/health"
+	"shortlink-go/internal/idgen"
+	"shortlink-go/internal/logging"
+	"shortlink-go/internal/metrics"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/ratelimit"
+	"shortlink-go/internal/repository"
+	"shortlink-go/internal/service"
+	"shortlink-go/internal/shortcode"
+	"shortlink-go/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key, as "Bearer <key>". Only required when AUTH_ENABLED is set.
+func main() {
+	cfg := config.LoadConfig()
+	logger, err := logging.New(os.Stdout, cfg.LogLevel)
+	if err != nil {
+		logging.Fatal("Invalid LOG_LEVEL", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(cfg, os.Args[2:])
+			return
+		case "apikey":
+			runAPIKey(cfg, os.Args[2:])
+			return
+		default:
+			logging.Fatal(`Unknown command, expected "migrate" or "apikey"`, "command", os.Args[1])
+		}
+	}
+
+	tracingEnabled := cfg.TracingExporter != "none"
+	var stopTracing func(context.Context) error
+	if tracingEnabled {
+		stopTracing, err = tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+		if err != nil {
+			logging.Fatal("Failed to set up tracing", "error", err)
+		}
+	}
+
+	var urlRepo repository.URLRepository
+	var clickRepo repository.ClickRepository
+	var apiKeyRepo repository.APIKeyRepository
+	var idBlockStore idgen.BlockStore // Nil when the storage can't reserve IDs.
+	var db *sql.DB                    // Nil with in-memory storage.
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		db = database.NewDB(cfg)
+		pgURLRepo := repository.NewPGURLRepository(db)
+		urlRepo = pgURLRepo
+		idBlockStore = pgURLRepo
+		clickRepo = repository.NewPGClickRepository(db)
+		apiKeyRepo = repository.NewPGAPIKeyRepository(db)
+	case config.StorageSQLite:
+		db = database.NewDB(cfg)
+		urlRepo = repository.NewSQLiteURLRepository(db)
+		clickRepo = repository.NewSQLiteClickRepository(db)
+		apiKeyRepo = repository.NewSQLiteAPIKeyRepository(db)
+	case config.StorageMemory:
+		slog.Warn("Using in-memory storage, links will be lost on restart")
+		urlRepo = repository.NewMemURLRepository()
+		clickRepo = repository.NewMemClickRepository()
+		if cfg.AuthEnabled {
+			logging.Fatal("AUTH_ENABLED requires a database to store API keys")
+		}
+	default:
+		logging.Fatal("Unknown storage driver", "driver", cfg.StorageDriver)
+	}
+
+	urlRepo = logging.LogURLRepository(urlRepo)
+	if cfg.MetricsEnabled {
+		if db != nil {
+			metrics.RegisterDB(db)
+		}
+		urlRepo = metrics.InstrumentURLRepository(urlRepo)
+	}
+
+	var redisClient cache.RedisClient = cache.NopClient{}
+	var rdb *redis.Client           // Nil when Redis is disabled.
+	var redisBreaker *cache.Breaker // Nil when Redis is disabled.
+	if cfg.RedisEnabled {
+		if cfg.RedisBreakerThreshold < 1 || cfg.RedisProbeInterval <= 0 {
+			logging.Fatal("REDIS_BREAKER_THRESHOLD and REDIS_PROBE_INTERVAL must be positive")
+		}
+		rdb, err = cache.NewRedisClient(cfg)
+		if tracingEnabled {
+			if err := redisotel.InstrumentTracing(rdb); err != nil {
+				logging.Fatal("Failed to trace Redis", "error", err)
+			}
+		}
+		redisBreaker = cache.NewBreaker(rdb, func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		}, cache.BreakerOptions{
+			Threshold:     cfg.RedisBreakerThreshold,
+			ProbeInterval: cfg.RedisProbeInterval,
+		})
+		if err != nil {
+			slog.Warn("Redis is unreachable, starting without it", "error", err)
+			redisBreaker.Trip()
+		}
+		if cfg.MetricsEnabled {
+			metrics.RegisterRedisBreaker(redisBreaker)
+		}
+		redisClient = redisBreaker
+	} else {
+		slog.Info("Redis is disabled")
+	}
+	var tiered *cache.TieredClient // Nil when the local cache is disabled.
+	switch {
+	case cfg.LocalCacheSize <= 0:
+	case rdb != nil:
+		tiered = cache.NewTieredClient(cache.NewLRU(cfg.LocalCacheSize, cfg.LocalCacheTTL), redisClient, rdb)
+	case cfg.StorageDriver == config.StorageMemory:
+		// Links in memory can't be shared, so no other instance serves them.
+		tiered = cache.NewTieredClient(cache.NewLRU(cfg.LocalCacheSize, cfg.LocalCacheTTL), redisClient, nil)
+	default:
+		slog.Warn("The local cache needs Redis to evict changed links on other instances, disabling it")
+	}
+	if tiered != nil {
+		if cfg.MetricsEnabled {
+			metrics.RegisterLocalCache(tiered)
+		}
+		redisClient = tiered
+	}
+
+	accessCounter := newAccessCounter(cfg, urlRepo, redisClient)
+	if cfg.MetricsEnabled {
+		metrics.RegisterAccessCounter(accessCounter)
+	}
+
+	var clickRecorder service.ClickRecorder
+	var recorder *analytics.Recorder // Nil when click recording is disabled.
+	if cfg.ClickRecordingEnabled {
+		if cfg.ClickFlushInterval <= 0 {
+			logging.Fatal("CLICK_FLUSH_INTERVAL must be positive")
+		}
+		recorder = analytics.NewRecorder(clickRepo, analytics.Options{
+			IPSalt:        clickIPSalt(cfg),
+			BufferSize:    cfg.ClickBufferSize,
+			BatchSize:     cfg.ClickBatchSize,
+			FlushInterval: cfg.ClickFlushInterval,
+		})
+		if cfg.MetricsEnabled {
+			metrics.RegisterClickRecorder(recorder)
+		}
+		clickRecorder = recorder
+	}
+
+	var srv service.IService = service.NewService(urlRepo, clickRepo, redisClient, accessCounter, clickRecorder, service.Options{
+		Dedupe:      cfg.DedupeLinks,
+		Codec:       newCodec(cfg),
+		IDGenerator: newIDGenerator(cfg, idBlockStore),
+		CacheTTL:    cfg.RedisCacheTTL,
+	})
+	if tracingEnabled {
+		srv = tracing.TraceService(srv)
+	}
+
+	checker := health.NewChecker(cfg.ReadinessTimeout)
+	if db != nil {
+		checker.Add("database", db.PingContext)
+	}
+	if redisBreaker != nil {
+		checker.AddOptional("redis", redisBreaker.Ping)
+	}
+	h := handler.NewHandler(srv, checker)
+
+	// Requests are logged by logging.Middleware rather than Gin's logger, and
+	// Gin's debug output would break up the JSON lines unless asked for.
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	if tracingEnabled {
+		r.Use(tracing.Middleware())
+	}
+	r.Use(logging.Middleware(), logging.Recovery())
+	var metricsServer *http.Server // Nil when metrics are disabled.
+	if cfg.MetricsEnabled {
+		r.Use(metrics.Middleware())
+		metricsServer = newMetricsServer(cfg.MetricsAddr)
+		go serve("metrics", metricsServer)
+	}
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logging.Fatal("Invalid trusted proxies", "error", err)
+	}
+	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	var mw handler.Middleware
+	if cfg.AuthEnabled {
+		mw.Auth = auth.NewAuthenticator(apiKeyRepo, cfg.APIKeyCacheTTL).Middleware()
+	} else {
+		slog.Warn("Authentication is disabled, anyone can create and manage links")
+	}
+	if cfg.RateLimitEnabled {
+		if !cfg.RedisEnabled {
+			logging.Fatal("RATE_LIMIT_ENABLED requires Redis to be enabled")
+		}
+		mw.RedirectLimit = rateLimit(redisClient, "redirect", cfg.RateLimitRedirect)
+		mw.CreateLimit = rateLimit(redisClient, "create", cfg.RateLimitCreate)
+		mw.APILimit = rateLimit(redisClient, "api", cfg.RateLimitAPI)
+	}
+	h.RegisterRoutes(r, mw)
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go serve("HTTP", server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop() // A second signal kills the process right away.
+
+	// Fail readiness and stop taking requests, letting those in flight finish,
+	// then write what they left buffered before closing the connections the
+	// writes need.
+	steps := []shutdownStep{
+		{"readiness", func(ctx context.Context) error {
+			return checker.Drain(ctx, cfg.ShutdownDelay)
+		}},
+		{"HTTP server", server.Shutdown},
+		{"access counter", accessCounter.Close},
+	}
+	if recorder != nil {
+		steps = append(steps, shutdownStep{"click recorder", recorder.Close})
+	}
+	if metricsServer != nil {
+		steps = append(steps, shutdownStep{"metrics server", metricsServer.Shutdown})
+	}
+	if db != nil {
+		steps = append(steps, shutdownStep{"database", ignoreContext(db.Close)})
+	}
+	if tiered != nil {
+		steps = append(steps, shutdownStep{"local cache", ignoreContext(tiered.Close)})
+	}
+	if rdb != nil {
+		steps = append(steps,
+			shutdownStep{"Redis circuit breaker", ignoreContext(redisBreaker.Close)},
+			shutdownStep{"Redis", ignoreContext(rdb.Close)},
+		)
+	}
+	steps = append(steps, shutdownStep{"tracing", stopTracing})
+	shutdown(cfg.ShutdownTimeout, steps...)
+}
+
+// newIDGenerator returns the generator of link IDs, or nil to let the database assign them.
+func newIDGenerator(cfg *config.Config, blockStore idgen.BlockStore) service.IDGenerator {
+	switch cfg.IDGenerator {
+	case "database":
+		return nil
+	case "snowflake":
+		g, err := idgen.NewSnowflake(cfg.IDNode)
+		if err != nil {
+			logging.Fatal("Invalid ID_NODE", "error", err)
+		}
+		return g
+	case "block":
+		if blockStore == nil {
+			logging.Fatal("ID_GENERATOR=block requires Postgres storage")
+		}
+		if cfg.IDBlockSize < 1 {
+			logging.Fatal("Invalid ID_BLOCK_SIZE", "size", cfg.IDBlockSize)
+		}
+		return idgen.NewBlock(blockStore, cfg.IDBlockSize)
+	default:
+		logging.Fatal(`Unknown ID generator, expected "database", "snowflake" or "block"`, "generator", cfg.IDGenerator)
+		return nil
+	}
+}
+
+func newCodec(cfg *config.Config) shortcode.Codec {
+	switch cfg.ShortLinkMode {
+	case "sequential":
+		return shortcode.Sequential{}
+	case "obfuscated":
+		if cfg.ShortLinkSecret == "" {
+			logging.Fatal("SHORT_LINK_MODE=obfuscated requires SHORT_LINK_SECRET to be set")
+		}
+		// Only IDs below 1<<48 are permuted, and snowflake IDs pass that within
+		// a day of the epoch, after which short links would be sequential again.
+		if cfg.IDGenerator == "snowflake" {
+			logging.Fatal("SHORT_LINK_MODE=obfuscated can't be used with ID_GENERATOR=snowflake")
+		}
+		return shortcode.NewObfuscated(cfg.ShortLinkSecret)
+	default:
+		logging.Fatal(`Unknown short link mode, expected "sequential" or "obfuscated"`, "mode", cfg.ShortLinkMode)
+		return nil
+	}
+}
+
+// clickIPSalt returns the key of the client IP hashes. Without one, the hashes
+// of IPv4 addresses could be reversed by trying them all, so outside local
+// development it must be set. Locally a random key is used, which makes the
+// hashes of one run incomparable with those of the next.
+func clickIPSalt(cfg *config.Config) string {
+	if cfg.ClickIPSalt != "" {
+		return cfg.ClickIPSalt
+	}
+	if cfg.Environment != config.Local {
+		logging.Fatal("CLICK_RECORDING_ENABLED requires CLICK_IP_SALT to be set", "environment", cfg.Environment)
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		logging.Fatal("Failed to generate click IP salt", "error", err)
+	}
+	slog.Warn("CLICK_IP_SALT is not set, hashing client IPs with a random key for this run")
+	return hex.EncodeToString(salt)
+}
+
+// rateLimit returns the middleware limiting a group of routes to rate, or nil
+// if rate has no limit.
+func rateLimit(redisClient cache.RedisClient, name string, rate config.Rate) gin.HandlerFunc {
+	if rate.Limit == 0 {
+		return nil
+	}
+	return ratelimit.NewLimiter(redisClient, name, rate).Middleware()
+}
+
+// newMetricsServer returns a server of /metrics on its own address.
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// closingCounter is an access counter that must be closed to persist buffered counts.
+type closingCounter interface {
+	service.AccessCounter
+	metrics.Backlogger
+	Close(ctx context.Context) error
+}
+
+func newAccessCounter(cfg *config.Config, urlRepo repository.URLRepository, redisClient cache.RedisClient) closingCounter {
+	if cfg.AccessCountFlushInterval <= 0 {
+		logging.Fatal("ACCESS_COUNT_FLUSH_INTERVAL must be positive")
+	}
+	overflow := counter.OverflowPolicy(cfg.AccessCountOverflow)
+	if overflow != counter.OverflowDrop && overflow != counter.OverflowBlock {
+		logging.Fatal(`Unknown access count overflow policy, expected "drop" or "block"`, "policy", overflow)
+	}
+	opts := counter.Options{
+		FlushInterval: cfg.AccessCountFlushInterval,
+		FlushSize:     cfg.AccessCountFlushSize,
+		BufferSize:    cfg.AccessCountBufferSize,
+		Overflow:      overflow,
+	}
+	switch cfg.AccessCountStore {
+	case "memory":
+		return counter.NewAggregator(urlRepo, opts)
+	case "redis":
+		if !cfg.RedisEnabled {
+			logging.Fatal("ACCESS_COUNT_STORE=redis requires Redis to be enabled")
+		}
+		// Accesses are buffered in memory while the circuit breaker skips Redis.
+		return counter.NewRedisCounter(redisClient, urlRepo, cfg.AccessCountFlushInterval, counter.NewAggregator(urlRepo, opts))
+	default:
+		logging.Fatal(`Unknown access count store, expected "memory" or "redis"`, "store", cfg.AccessCountStore)
+		return nil
+	}
+}
+
+// runMigrate implements "migrate up", "migrate down [steps]" and "migrate status".
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		logging.Fatal("Usage: shortlink-go migrate up|down [steps]|status")
+	}
+
+	db := database.NewDB(cfg)
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(ctx, db, cfg.StorageDriver)
+		if err != nil {
+			logging.Fatal("Migration failed", "error", err)
+		}
+		slog.Info("Applied migrations", "count", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				logging.Fatal("Invalid number of steps", "steps", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := database.MigrateDown(ctx, db, cfg.StorageDriver, steps)
+		if err != nil {
+			logging.Fatal("Rollback failed", "error", err)
+		}
+		slog.Info("Rolled back migrations", "count", rolledBack)
+	case "status":
+		statuses, err := database.Status(ctx, db, cfg.StorageDriver)
+		if err != nil {
+			logging.Fatal("Failed to read migration status", "error", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	default:
+		logging.Fatal("Unknown migrate command, expected up, down or status", "command", args[0])
+	}
+}
+
+// runAPIKey implements "apikey create <name>", "apikey list" and "apikey revoke <id>".
+func runAPIKey(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		logging.Fatal("Usage: shortlink-go apikey create <name>|list|revoke <id>")
+	}
+
+	var repo repository.APIKeyRepository
+	db := database.NewDB(cfg)
+	defer db.Close()
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		repo = repository.NewPGAPIKeyRepository(db)
+	case config.StorageSQLite:
+		repo = repository.NewSQLiteAPIKeyRepository(db)
+	default:
+		logging.Fatal("API keys are not supported with this storage driver", "driver", cfg.StorageDriver)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 || args[1] == "" {
+			logging.Fatal("Usage: shortlink-go apikey create <name>")
+		}
+		key, hash, err := auth.GenerateKey()
+		if err != nil {
+			logging.Fatal("Failed to generate API key", "error", err)
+		}
+		id, err := repo.CreateAPIKey(ctx, &model.APIKey{Name: args[1], KeyHash: hash})
+		if err != nil {
+			logging.Fatal("Failed to create API key", "error", err)
+		}
+		slog.Info("Created API key. Store it now, it can't be shown again.", "id", id)
+		fmt.Println(key)
+	case "list":
+		keys, err := repo.ListAPIKeys(ctx)
+		if err != nil {
+			logging.Fatal("Failed to list API keys", "error", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, k := range keys {
+			status := "active"
+			if k.RevokedAt != nil {
+				status = "revoked " + k.RevokedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%d\t%s\tcreated %s\t%s\n", k.ID, k.Name, k.CreatedAt.Format("2006-01-02 15:04:05 MST"), status)
+		}
+		w.Flush()
+	case "revoke":
+		if len(args) < 2 {
+			logging.Fatal("Usage: shortlink-go apikey revoke <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			logging.Fatal("Invalid API key ID", "id", args[1])
+		}
+		if err := repo.RevokeAPIKey(ctx, id); err != nil {
+			if errors.Is(err, repository.ErrKeyNotFound) {
+				logging.Fatal("No active API key with this ID", "id", id)
+			}
+			logging.Fatal("Failed to revoke API key", "error", err)
+		}
+		slog.Info("Revoked API key", "id", id)
+	default:
+		logging.Fatal("Unknown apikey command, expected create, list or revoke", "command", args[0])
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"shortlink-go/internal/logging"
+)
+
+// shutdownStep stops one part of the service. A nil stop is skipped, for
+// parts that aren't enabled.
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// shutdown runs steps in order, sharing timeout between them. Steps still run
+// once the deadline has passed, so connections are closed either way, but
+// steps waiting on ctx give up right away.
+func shutdown(timeout time.Duration, steps ...shutdownStep) {
+	slog.Info("Shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, step := range steps {
+		if step.stop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := step.stop(ctx); err != nil {
+			slog.Error("Failed to stop "+step.name, "error", err, "duration", time.Since(start))
+			continue
+		}
+		slog.Info("Stopped "+step.name, "duration", time.Since(start))
+	}
+}
+
+// serve runs server until it is shut down.
+func serve(name string, server *http.Server) {
+	slog.Info("Serving "+name, "addr", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logging.Fatal("Failed to serve "+name, "error", err)
+	}
+}
+
+// ignoreContext adapts a Close method to a shutdownStep.
+func ignoreContext(close func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return close()
+	}
+}
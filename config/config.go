@@ -1,7 +1,14 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -15,33 +22,184 @@ const (
 	Production Environment = "production"
 )
 
+type StorageDriver string
+
+const (
+	StoragePostgres StorageDriver = "postgres"
+	StorageSQLite   StorageDriver = "sqlite"
+	StorageMemory   StorageDriver = "memory"
+)
+
+// Rate is a request limit per period, written as "<requests>/<period>", e.g.
+// "100/1m". An empty value or a limit of 0 means no limit.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Decode implements envconfig.Decoder.
+func (r *Rate) Decode(value string) error {
+	if value == "" || value == "0" {
+		*r = Rate{}
+		return nil
+	}
+	limit, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("invalid rate %q, expected <requests>/<period>", value)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid request limit in rate %q", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid period in rate %q", value)
+	}
+	*r = Rate{Limit: n, Period: d}
+	return nil
+}
+
 type Config struct {
 	Environment Environment `envconfig:"ENVIRONMENT" default:"local"`
 
 	Port string `envconfig:"PORT" default:"8080"`
 
+	// How long shutdown may take: once a SIGTERM or SIGINT arrives, requests in
+	// flight are finished and buffered access counts and clicks are written
+	// within this time before connections are closed. Keep it below the grace
+	// period of the orchestrator, e.g. 30s on Kubernetes.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"20s"`
+	// How long /readyz fails before the server stops taking requests, so load
+	// balancers stop sending new ones first. Part of the shutdown timeout.
+	ShutdownDelay time.Duration `envconfig:"SHUTDOWN_DELAY" default:"0s"`
+
+	// How long /readyz waits for each dependency to answer a ping.
+	ReadinessTimeout time.Duration `envconfig:"READINESS_TIMEOUT" default:"2s"`
+
+	// Logs are written to stdout as JSON lines. Records below the level, "debug",
+	// "info", "warn" or "error", are dropped.
+	LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
+
+	// Where links are stored: "postgres", "sqlite" or "memory". "memory" needs no
+	// database and loses all links on restart.
+	StorageDriver StorageDriver `envconfig:"STORAGE_DRIVER" default:"postgres"`
+
+	// Path of the database file when StorageDriver is "sqlite".
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"shortlink.db"`
+
 	DBHost     string `envconfig:"DB_HOST" default:"localhost"`
 	DBPort     int    `envconfig:"DB_PORT" default:"5432"`
 	DBUser     string `envconfig:"DB_USER" default:"postgres"`
 	DBPassword string `envconfig:"DB_PASSWORD" default:"password"`
 	DBName     string `envconfig:"DB_NAME" default:"db"`
+	// Apply pending schema migrations when connecting on startup.
+	DBAutoMigrate bool `envconfig:"DB_AUTO_MIGRATE" default:"false"`
 
+	// With Redis disabled, lookups only use the local cache and the database.
+	RedisEnabled  bool   `envconfig:"REDIS_ENABLED" default:"true"`
 	RedisHost     string `envconfig:"REDIS_HOST" default:"localhost"`
 	RedisPort     string `envconfig:"REDIS_PORT" default:"6379"`
 	RedisPassword string `envconfig:"REDIS_PASSWORD" default:""`
 	RedisDB       int    `envconfig:"REDIS_DB" default:"0"`
+	// After this many Redis commands fail in a row, Redis is skipped as if it
+	// were disabled, and pinged every probe interval until it answers again.
+	RedisBreakerThreshold int           `envconfig:"REDIS_BREAKER_THRESHOLD" default:"5"`
+	RedisProbeInterval    time.Duration `envconfig:"REDIS_PROBE_INTERVAL" default:"5s"`
+	// How long links, aliases and deduplicated URLs stay cached in Redis at
+	// most. This bounds how long an entry that couldn't be evicted, e.g. during
+	// an outage, may be served once Redis is back. 0 keeps entries until evicted.
+	RedisCacheTTL time.Duration `envconfig:"REDIS_CACHE_TTL" default:"1h"`
+
+	// Require an API key for creating, changing and reading the stats of links.
+	// Redirects stay public. Keys are managed with "shortlink-go apikey" and
+	// cached for the cache TTL, which bounds how long a revoked key keeps working.
+	AuthEnabled    bool          `envconfig:"AUTH_ENABLED" default:"false"`
+	APIKeyCacheTTL time.Duration `envconfig:"API_KEY_CACHE_TTL" default:"1m"`
+
+	// Serve Prometheus metrics at /metrics on their own address, so they can
+	// be kept off the public port.
+	MetricsEnabled bool   `envconfig:"METRICS_ENABLED" default:"false"`
+	MetricsAddr    string `envconfig:"METRICS_ADDR" default:":9090"`
+
+	// Where OpenTelemetry spans go: "none", "stdout" or "otlp" (OTLP over HTTP,
+	// configured with the standard OTEL_EXPORTER_OTLP_* variables). The ratio
+	// is the share of traces sampled when the caller didn't decide.
+	TracingExporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	// Proxies whose X-Forwarded-For header is trusted to give the client IP,
+	// as IPs or CIDRs. With none, the IP of the connection is used.
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES" default:""`
+
+	// Per-client request limits, shared by all instances through Redis. Clients
+	// are identified by their API key, or by IP without one. Redirects, link
+	// creation (single and batch) and the other API routes have separate limits.
+	RateLimitEnabled  bool `envconfig:"RATE_LIMIT_ENABLED" default:"false"`
+	RateLimitRedirect Rate `envconfig:"RATE_LIMIT_REDIRECT" default:"600/1m"`
+	RateLimitCreate   Rate `envconfig:"RATE_LIMIT_CREATE" default:"30/1m"`
+	RateLimitAPI      Rate `envconfig:"RATE_LIMIT_API" default:"300/1m"`
+
+	// Where the IDs of new links come from: "database" (the ID sequence, on
+	// insert), "snowflake" (made up locally from the time and IDNode, which must
+	// be unique per instance) or "block" (blocks of IDBlockSize IDs reserved from
+	// the Postgres sequence). All instances must use the same generator.
+	IDGenerator string `envconfig:"ID_GENERATOR" default:"database"`
+	IDNode      int64  `envconfig:"ID_NODE" default:"0"`
+	IDBlockSize int64  `envconfig:"ID_BLOCK_SIZE" default:"10000"`
+
+	// How link IDs become short links: "sequential" encodes them as they are,
+	// while "obfuscated" first permutes them with a Feistel network keyed with
+	// the secret, so short links can't be enumerated. Changing either setting
+	// changes the ID that existing short links resolve to. Snowflake IDs are too
+	// large to permute, so "obfuscated" needs another ID generator.
+	ShortLinkMode   string `envconfig:"SHORT_LINK_MODE" default:"sequential"`
+	ShortLinkSecret string `envconfig:"SHORT_LINK_SECRET" default:""`
+
+	// Return the existing short link when the same normalized URL is shortened
+	// again, unless the request sets "dedupe". Links with an alias or expiry
+	// are never deduplicated. This is best-effort: concurrent requests for a new
+	// URL may each create a link.
+	DedupeLinks bool `envconfig:"DEDUPE_LINKS" default:"false"`
+
+	// In-process LRU in front of Redis. A size of 0 disables it. Changes are
+	// published to the other instances through Redis, so without Redis it is
+	// only used with the memory storage driver.
+	LocalCacheSize int           `envconfig:"LOCAL_CACHE_SIZE" default:"10000"`
+	LocalCacheTTL  time.Duration `envconfig:"LOCAL_CACHE_TTL" default:"1m"`
+
+	// Where access counts are buffered before being written to the database:
+	// "memory" (per instance) or "redis" (shared HINCRBY counters, synced every
+	// flush interval). In memory, counts are written every flush interval, or
+	// sooner once flush size distinct links are pending. When the buffer is full,
+	// the overflow policy either drops increments ("drop") or makes redirects wait ("block").
+	AccessCountStore         string        `envconfig:"ACCESS_COUNT_STORE" default:"memory"`
+	AccessCountFlushInterval time.Duration `envconfig:"ACCESS_COUNT_FLUSH_INTERVAL" default:"1s"`
+	AccessCountFlushSize     int           `envconfig:"ACCESS_COUNT_FLUSH_SIZE" default:"1000"`
+	AccessCountBufferSize    int           `envconfig:"ACCESS_COUNT_BUFFER_SIZE" default:"10000"`
+	AccessCountOverflow      string        `envconfig:"ACCESS_COUNT_OVERFLOW" default:"drop"`
+
+	// Per-redirect click events for analytics. Client IPs are stored as an HMAC
+	// keyed with the salt, a secret that must be set outside the local
+	// environment. Locally a random one is used when it is empty.
+	ClickRecordingEnabled bool          `envconfig:"CLICK_RECORDING_ENABLED" default:"true"`
+	ClickIPSalt           string        `envconfig:"CLICK_IP_SALT" default:""`
+	ClickBufferSize       int           `envconfig:"CLICK_BUFFER_SIZE" default:"10000"`
+	ClickBatchSize        int           `envconfig:"CLICK_BATCH_SIZE" default:"500"`
+	ClickFlushInterval    time.Duration `envconfig:"CLICK_FLUSH_INTERVAL" default:"1s"`
 }
 
 func LoadConfig() *Config {
+	// Runs before the logger is configured, so this goes to the default logger.
 	err := godotenv.Load()
-	if err != nil {
-		log.Println("Error loading .env file, continuing without it")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to load .env file, continuing without it", "error", err)
 	}
 
 	var c Config
 	err = envconfig.Process("", &c)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
 
 	return &c
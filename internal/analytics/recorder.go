@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"shortlink-go/internal/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// maxFieldLength caps the stored length of client-supplied headers.
+const maxFieldLength = 512
+
+// ClickWriter stores a batch of click events. repository.ClickRepository implements it.
+type ClickWriter interface {
+	RecordClicks(ctx context.Context, clicks []model.Click) error
+}
+
+type Options struct {
+	IPSalt        string        // Key of the client IP hash.
+	BufferSize    int           // Clicks queued before new ones are dropped.
+	BatchSize     int           // Write as soon as this many clicks are queued.
+	FlushInterval time.Duration // Write at least this often while clicks are queued.
+}
+
+// Recorder stores click events in batches from a background goroutine, so
+// recording never adds database latency to redirects.
+type Recorder struct {
+	repo     ClickWriter
+	opts     Options
+	queue    chan model.Click
+	mu       sync.RWMutex // Guards closed against concurrent Records.
+	closed   bool
+	closeCtx context.Context // Bounds the final write, set before the queue is closed.
+	done     chan struct{}
+	dropped  atomic.Int64
+}
+
+// NewRecorder starts a recorder writing to repo. Call Close to stop it.
+func NewRecorder(repo ClickWriter, opts Options) *Recorder {
+	r := &Recorder{
+		repo:  repo,
+		opts:  opts,
+		queue: make(chan model.Click, opts.BufferSize),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues a click, replacing clientIP with its hash. When the queue is
+// full the click is dropped rather than slowing down the redirect.
+func (r *Recorder) Record(click model.Click, clientIP string) {
+	click.IPHash = r.hashIP(clientIP)
+	click.Referrer = sanitize(click.Referrer)
+	click.UserAgent = sanitize(click.UserAgent)
+	click.AcceptLanguage = sanitize(click.AcceptLanguage)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.queue <- click:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many clicks were discarded because the queue was full.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Queued returns how many clicks wait to be written.
+func (r *Recorder) Queued() int {
+	return len(r.queue)
+}
+
+// Close stops accepting clicks and writes the queued ones, giving up when ctx is done.
+func (r *Recorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		r.closeCtx = ctx
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]model.Click, 0, r.opts.BatchSize)
+	for {
+		select {
+		case click, ok := <-r.queue:
+			if !ok {
+				r.write(r.closeCtx, batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= r.opts.BatchSize {
+				r.write(context.Background(), batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.write(context.Background(), batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// write stores batch, giving up after 10s or when parent is done.
+func (r *Recorder) write(parent context.Context, batch []model.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+	if err := r.repo.RecordClicks(ctx, batch); err != nil {
+		slog.Error("Failed to record clicks", "count", len(batch), "error", err)
+	}
+}
+
+func (r *Recorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(r.opts.IPSalt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sanitize makes a header value safe to store: valid UTF-8 without NUL bytes
+// (which Postgres rejects) and at most maxFieldLength bytes.
+func sanitize(s string) string {
+	s = strings.ToValidUTF8(strings.ReplaceAll(s, "\x00", ""), "")
+	if len(s) <= maxFieldLength {
+		return s
+	}
+	n := maxFieldLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,61 @@
+package analytics_test
+
+import (
+	"context"
+	"shortlink-go/internal/analytics"
+	"shortlink-go/internal/model"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClickRepository struct {
+	mu     sync.Mutex
+	clicks []model.Click
+}
+
+func (r *fakeClickRepository) RecordClicks(ctx context.Context, clicks []model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clicks = append(r.clicks, clicks...)
+	return nil
+}
+
+func TestRecorder_WritesHashedClicksOnClose(t *testing.T) {
+	repo := &fakeClickRepository{}
+	r := analytics.NewRecorder(repo, analytics.Options{
+		IPSalt:        "secret",
+		BufferSize:    10,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	r.Record(model.Click{URLID: 1, UserAgent: strings.Repeat("a", 1000)}, "203.0.113.7")
+	r.Record(model.Click{URLID: 1}, "203.0.113.7")
+	assert.NoError(t, r.Close(context.Background()))
+
+	assert.Len(t, repo.clicks, 2)
+	assert.Len(t, repo.clicks[0].IPHash, 64)
+	assert.NotContains(t, repo.clicks[0].IPHash, "203.0.113.7")
+	assert.Equal(t, repo.clicks[0].IPHash, repo.clicks[1].IPHash)
+	assert.Len(t, repo.clicks[0].UserAgent, 512)
+}
+
+func TestRecorder_DropsWhenFull(t *testing.T) {
+	repo := &fakeClickRepository{}
+	r := analytics.NewRecorder(repo, analytics.Options{
+		BufferSize:    0,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	// Without a buffer, clicks are only accepted while the writer is waiting for one.
+	for i := 0; i < 100; i++ {
+		r.Record(model.Click{URLID: 1}, "")
+	}
+	assert.NoError(t, r.Close(context.Background()))
+	assert.Equal(t, int64(100), int64(len(repo.clicks))+r.Dropped())
+}
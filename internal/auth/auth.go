@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"shortlink-go/internal/logging"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyPrefix starts every API key, so leaked keys are easy to recognize.
+const KeyPrefix = "slk_"
+
+// GenerateKey returns a new random API key and the hash to store for it.
+func GenerateKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashKey(key), nil
+}
+
+// HashKey returns the stored hash of an API key. Keys are long and random, so
+// unlike passwords they don't need a slow hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the API key of the request.
+func NewContext(ctx context.Context, key *model.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the API key of the request, if it was authenticated.
+func FromContext(ctx context.Context) (*model.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(*model.APIKey)
+	return key, ok
+}
+
+// KeyStore looks up API keys, e.g. repository.APIKeyRepository.
+type KeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+}
+
+type cachedKey struct {
+	key       *model.APIKey
+	expiresAt time.Time
+}
+
+// Authenticator checks the API keys of requests. Valid keys are cached for a
+// TTL, which bounds how long a revoked key keeps working.
+type Authenticator struct {
+	store KeyStore
+	ttl   time.Duration
+	mu    sync.Mutex
+	cache map[string]cachedKey // By key hash. Only holds valid keys, so it stays small.
+}
+
+func NewAuthenticator(store KeyStore, ttl time.Duration) *Authenticator {
+	return &Authenticator{
+		store: store,
+		ttl:   ttl,
+		cache: make(map[string]cachedKey),
+	}
+}
+
+// Middleware rejects requests without a valid API key, passed as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", and stores the key in
+// the request context.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw := ctx.GetHeader("X-API-Key")
+		if bearer, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer "); ok {
+			raw = bearer
+		}
+		if raw == "" {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+			return
+		}
+
+		key, err := a.lookup(ctx, HashKey(raw))
+		if err != nil {
+			if errors.Is(err, repository.ErrKeyNotFound) {
+				ctx.Header("WWW-Authenticate", "Bearer")
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				return
+			}
+			logging.FromContext(ctx.Request.Context()).Error("Failed to look up API key", "error", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check API key"})
+			return
+		}
+
+		ctx.Request = ctx.Request.WithContext(NewContext(ctx.Request.Context(), key))
+		ctx.Next()
+	}
+}
+
+func (a *Authenticator) lookup(ctx context.Context, hash string) (*model.APIKey, error) {
+	a.mu.Lock()
+	cached, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	key, err := a.store.GetAPIKeyByHash(ctx, hash)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		delete(a.cache, hash)
+		return nil, err
+	}
+	a.cache[hash] = cachedKey{key: key, expiresAt: time.Now().Add(a.ttl)}
+	return key, nil
+}
@@ -0,0 +1,75 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shortlink-go/internal/auth"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter(authenticator *auth.Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", authenticator.Middleware(), func(ctx *gin.Context) {
+		key, _ := auth.FromContext(ctx.Request.Context())
+		ctx.String(http.StatusOK, key.Name)
+	})
+	return r
+}
+
+func get(r *gin.Engine, header, value string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	repo := repository.NewMemAPIKeyRepository()
+	key, hash, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, auth.KeyPrefix))
+	_, err = repo.CreateAPIKey(context.Background(), &model.APIKey{Name: "ci", KeyHash: hash})
+	assert.NoError(t, err)
+	r := newRouter(auth.NewAuthenticator(repo, time.Minute))
+
+	resp := get(r, "Authorization", "Bearer "+key)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "ci", resp.Body.String())
+
+	resp = get(r, "X-API-Key", key)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = get(r, "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.JSONEq(t, `{"error":"Missing API key"}`, resp.Body.String())
+	assert.Equal(t, "Bearer", resp.Header().Get("WWW-Authenticate"))
+
+	resp = get(r, "Authorization", "Bearer "+auth.KeyPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.JSONEq(t, `{"error":"Invalid API key"}`, resp.Body.String())
+}
+
+func TestAuthenticator_Revoked(t *testing.T) {
+	repo := repository.NewMemAPIKeyRepository()
+	key, hash, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	id, err := repo.CreateAPIKey(context.Background(), &model.APIKey{Name: "ci", KeyHash: hash})
+	assert.NoError(t, err)
+	r := newRouter(auth.NewAuthenticator(repo, 0))
+
+	assert.Equal(t, http.StatusOK, get(r, "X-API-Key", key).Code)
+	assert.NoError(t, repo.RevokeAPIKey(context.Background(), id))
+	assert.Equal(t, http.StatusUnauthorized, get(r, "X-API-Key", key).Code)
+}
@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen is returned instead of running a command while Redis is
+// considered down.
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+// BreakerOptions configures a Breaker.
+type BreakerOptions struct {
+	// Consecutive failed commands after which the breaker opens.
+	Threshold int
+	// How often Redis is pinged while the breaker is open, and how long a ping
+	// may take.
+	ProbeInterval time.Duration
+}
+
+// Breaker is a RedisClient that stops sending commands to Redis once they keep
+// failing, so requests don't each wait for a timeout while it is down. While
+// the breaker is open, commands fail right away with ErrCircuitOpen and callers
+// fall back to the database. Redis is pinged in the background meanwhile, and
+// the breaker closes again once a ping succeeds.
+//
+// Del still goes to Redis while the breaker is open: skipping it would leave
+// stale entries behind for other instances, and changes to links are rare
+// enough to afford the wait.
+type Breaker struct {
+	client   RedisClient
+	ping     func(ctx context.Context) error
+	opts     BreakerOptions
+	failures atomic.Int64
+	open     atomic.Bool
+	skipped  atomic.Int64
+
+	mu      sync.Mutex // Guards closed and starting probes.
+	closed  bool
+	stop    chan struct{}
+	probing sync.WaitGroup
+}
+
+// NewBreaker returns a closed breaker in front of client, probing Redis with
+// ping while open. Call Close to stop probing.
+func NewBreaker(client RedisClient, ping func(ctx context.Context) error, opts BreakerOptions) *Breaker {
+	return &Breaker{
+		client: client,
+		ping:   ping,
+		opts:   opts,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Open reports whether commands are currently skipped.
+func (b *Breaker) Open() bool {
+	return b.open.Load()
+}
+
+// Skipped returns how many commands failed with ErrCircuitOpen.
+func (b *Breaker) Skipped() int64 {
+	return b.skipped.Load()
+}
+
+// Trip opens the breaker, e.g. when Redis can't be reached on startup.
+func (b *Breaker) Trip() {
+	if !b.open.CompareAndSwap(false, true) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.probing.Add(1)
+	go b.probe()
+}
+
+// Ping pings Redis, or fails with ErrCircuitOpen while the breaker is open.
+func (b *Breaker) Ping(ctx context.Context) error {
+	if b.Open() {
+		return ErrCircuitOpen
+	}
+	err := b.ping(ctx)
+	b.record(ctx, err)
+	return err
+}
+
+// Close stops probing.
+func (b *Breaker) Close() error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mu.Unlock()
+	b.probing.Wait()
+	return nil
+}
+
+// skip reports whether a command must be skipped, counting it if so.
+func (b *Breaker) skip() bool {
+	if !b.Open() {
+		return false
+	}
+	b.skipped.Add(1)
+	return true
+}
+
+func (b *Breaker) probe() {
+	defer b.probing.Done()
+
+	ticker := time.NewTicker(b.opts.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), b.opts.ProbeInterval)
+			err := b.ping(ctx)
+			cancel()
+			if err != nil {
+				slog.Debug("Redis is still unreachable", "error", err)
+				continue
+			}
+			b.failures.Store(0)
+			b.open.Store(false)
+			slog.Info("Redis is reachable again, closing the circuit breaker")
+			return
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// record counts err towards opening the breaker. Replies from Redis, such as
+// redis.Nil for missing keys, and requests given up by the caller show that
+// Redis is up rather than down.
+func (b *Breaker) record(ctx context.Context, err error) {
+	var reply redis.Error
+	if err == nil || errors.As(err, &reply) || ctx.Err() != nil {
+		b.failures.Store(0)
+		return
+	}
+	if b.failures.Add(1) >= int64(b.opts.Threshold) && !b.Open() {
+		slog.Warn("Redis keeps failing, opening the circuit breaker", "failures", b.opts.Threshold, "error", err)
+		b.Trip()
+	}
+}
+
+func (b *Breaker) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	if b.skip() {
+		return redis.NewStatusResult("", ErrCircuitOpen)
+	}
+	cmd := b.client.Set(ctx, key, value, expiration)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) Get(ctx context.Context, key string) *redis.StringCmd {
+	if b.skip() {
+		return redis.NewStringResult("", ErrCircuitOpen)
+	}
+	cmd := b.client.Get(ctx, key)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	cmd := b.client.Del(ctx, keys...)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	if b.skip() {
+		return redis.NewDurationResult(0, ErrCircuitOpen)
+	}
+	cmd := b.client.PTTL(ctx, key)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd {
+	if b.skip() {
+		return redis.NewIntResult(0, ErrCircuitOpen)
+	}
+	cmd := b.client.HIncrBy(ctx, key, field, incr)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	if b.skip() {
+		return redis.NewStringResult("", ErrCircuitOpen)
+	}
+	cmd := b.client.HGet(ctx, key, field)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	if b.skip() {
+		return redis.NewCmdResult(nil, ErrCircuitOpen)
+	}
+	cmd := b.client.Eval(ctx, script, keys, args...)
+	b.record(ctx, cmd.Err())
+	return cmd
+}
+
+func (b *Breaker) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	if b.skip() {
+		return nil, ErrCircuitOpen
+	}
+	cmds, err := b.client.Pipelined(ctx, fn)
+	b.record(ctx, err)
+	return cmds, err
+}
@@ -0,0 +1,85 @@
+package cache_test
+
+import (
+	"context"
+	"shortlink-go/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBreaker(t *testing.T, server *miniredis.Miniredis, probeInterval time.Duration) *cache.Breaker {
+	client := redis.NewClient(&redis.Options{
+		Addr:        server.Addr(),
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	t.Cleanup(func() { client.Close() })
+	b := cache.NewBreaker(client, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}, cache.BreakerOptions{Threshold: 2, ProbeInterval: probeInterval})
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestBreaker_RepliesKeepItClosed(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := newBreaker(t, server, 10*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, b.Get(ctx, "missing").Err(), redis.Nil)
+	}
+	assert.False(t, b.Open())
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := newBreaker(t, server, 10*time.Millisecond)
+	ctx := context.Background()
+	require.NoError(t, b.Set(ctx, "k", "v", 0).Err())
+
+	server.Close()
+	assert.Error(t, b.Get(ctx, "k").Err())
+	assert.False(t, b.Open())
+	assert.Error(t, b.Get(ctx, "k").Err())
+	assert.True(t, b.Open())
+
+	// Commands are skipped without reaching Redis.
+	assert.ErrorIs(t, b.Get(ctx, "k").Err(), cache.ErrCircuitOpen)
+	assert.ErrorIs(t, b.Eval(ctx, "return 1", nil).Err(), cache.ErrCircuitOpen)
+	_, err := b.Pipelined(ctx, func(redis.Pipeliner) error { return nil })
+	assert.ErrorIs(t, err, cache.ErrCircuitOpen)
+	assert.ErrorIs(t, b.Ping(ctx), cache.ErrCircuitOpen)
+
+	require.NoError(t, server.Restart())
+	assert.Eventually(t, func() bool { return !b.Open() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "v", b.Get(ctx, "k").Val())
+}
+
+func TestBreaker_DelWhileOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := newBreaker(t, server, time.Hour)
+	ctx := context.Background()
+	require.NoError(t, server.Set("k", "v"))
+
+	b.Trip()
+
+	assert.True(t, b.Open())
+	assert.NoError(t, b.Del(ctx, "k").Err())
+	assert.False(t, server.Exists("k"))
+}
+
+func TestBreaker_Trip(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := newBreaker(t, server, 10*time.Millisecond)
+
+	b.Trip()
+
+	assert.True(t, b.Open())
+	assert.Eventually(t, func() bool { return !b.Open() }, time.Second, 10*time.Millisecond)
+}
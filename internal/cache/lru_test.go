@@ -0,0 +1,113 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	lru := cache.NewLRU(2, time.Minute)
+
+	lru.Set("a", "1", 0)
+	lru.Set("b", "2", 0)
+	_, _ = lru.Get("a") // "b" is now the least recently used.
+	lru.Set("c", "3", 0)
+
+	_, ok := lru.Get("b")
+	assert.False(t, ok)
+	value, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+	_, ok = lru.Get("c")
+	assert.True(t, ok)
+
+	stats := lru.Stats()
+	assert.Equal(t, int64(3), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	lru := cache.NewLRU(10, time.Minute)
+
+	lru.Set("short", "1", time.Millisecond)
+	lru.Set("long", "2", time.Hour) // Capped at the cache TTL.
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := lru.Get("short")
+	assert.False(t, ok)
+	_, ok = lru.Get("long")
+	assert.True(t, ok)
+}
+
+func TestTieredClient_WithoutRedis(t *testing.T) {
+	client := cache.NewTieredClient(cache.NewLRU(10, time.Minute), cache.NopClient{}, nil)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "key").Result()
+	assert.ErrorIs(t, err, redis.Nil)
+
+	assert.NoError(t, client.Set(ctx, "key", int64(42), 0).Err())
+	value, err := client.Get(ctx, "key").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "42", value)
+	assert.Equal(t, int64(1), client.Stats().Hits)
+}
+
+// newTieredClients returns two TieredClients sharing one Redis, as on two
+// instances.
+func newTieredClients(t *testing.T, server *miniredis.Miniredis) (*cache.TieredClient, *cache.TieredClient) {
+	clients := make([]*cache.TieredClient, 2)
+	for i := range clients {
+		rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { rdb.Close() })
+		clients[i] = cache.NewTieredClient(cache.NewLRU(10, time.Minute), rdb, rdb)
+		t.Cleanup(func() { clients[i].Close() })
+	}
+	require.Eventually(t, func() bool {
+		return server.PubSubNumSub(cache.EvictionChannel)[cache.EvictionChannel] == 2
+	}, time.Second, 10*time.Millisecond)
+	return clients[0], clients[1]
+}
+
+func TestTieredClient_DelEvictsOtherInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	a, b := newTieredClients(t, server)
+	ctx := context.Background()
+
+	require.NoError(t, a.Set(ctx, "key", "old", 0).Err())
+	require.Equal(t, "old", b.Get(ctx, "key").Val())
+	require.Equal(t, int64(0), b.Stats().Hits)
+	require.Equal(t, "old", b.Get(ctx, "key").Val())
+	require.Equal(t, int64(1), b.Stats().Hits) // Now cached locally.
+
+	require.NoError(t, a.Del(ctx, "key").Err())
+
+	assert.Eventually(t, func() bool {
+		return errors.Is(b.Get(ctx, "key").Err(), redis.Nil)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTieredClient_SetEvictsOtherInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	a, b := newTieredClients(t, server)
+	ctx := context.Background()
+
+	require.NoError(t, a.Set(ctx, "key", "old", 0).Err())
+	require.Equal(t, "old", b.Get(ctx, "key").Val())
+
+	require.NoError(t, a.Set(ctx, "key", "new", 0).Err())
+
+	assert.Eventually(t, func() bool {
+		return b.Get(ctx, "key").Val() == "new"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "new", a.Get(ctx, "key").Val())
+}
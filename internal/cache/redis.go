@@ -2,7 +2,7 @@ package cache
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"shortlink-go/config"
 	"time"
 
@@ -12,9 +12,17 @@ import (
 type RedisClient interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	PTTL(ctx context.Context, key string) *redis.DurationCmd
+	HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd
+	HGet(ctx context.Context, key, field string) *redis.StringCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
 }
 
-func NewRedisClient(cfg *config.Config) *redis.Client {
+// NewRedisClient returns a client of the configured Redis along with the error
+// of a first ping. The client is usable either way, as it connects on demand.
+func NewRedisClient(cfg *config.Config) (*redis.Client, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     cfg.RedisHost + ":" + cfg.RedisPort,
 		Password: cfg.RedisPassword, // no password set
@@ -22,11 +30,12 @@ func NewRedisClient(cfg *config.Config) *redis.Client {
 	})
 
 	// Verify connection
-	err := rdb.Ping(context.Background()).Err()
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return rdb, err
 	}
 
-	log.Println("Connected to Redis successfully.")
-	return rdb
+	slog.Info("Connected to Redis")
+	return rdb, nil
 }
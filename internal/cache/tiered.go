@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EvictionChannel is the Redis channel on which TieredClients tell each other
+// which keys changed, so they drop their local copies.
+const EvictionChannel = "shortlink:evictions"
+
+// resubscribeDelay is how long to wait before receiving again after the
+// eviction subscription failed, e.g. while Redis is down.
+const resubscribeDelay = time.Second
+
+// PubSub carries evictions between instances, e.g. *redis.Client.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// TieredClient is a RedisClient that serves reads from an in-process LRU before
+// falling back to the remote client, so hot links skip the Redis round trip.
+// Writes go to both tiers. Entries never outlive their Redis TTL.
+//
+// Keys written or deleted through a TieredClient are published on
+// EvictionChannel, and the TieredClients of other instances drop their local
+// copies when they receive them. Whenever evictions may have been missed, e.g.
+// while Redis was down, the whole local cache is dropped.
+type TieredClient struct {
+	local  *LRU
+	remote RedisClient
+	pubsub PubSub // Nil when this is the only instance.
+	id     string // Tells evictions published by this client apart.
+
+	// evictions changes whenever local copies are dropped, so a value read
+	// from the remote client before an eviction isn't cached after it.
+	evictions atomic.Uint64
+	sub       *redis.PubSub
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewTieredClient returns a client caching the values of remote in local. With
+// a pubsub, it shares evictions with other instances until Close is called;
+// without one, other instances may serve a changed value until it expires from
+// their LRU.
+func NewTieredClient(local *LRU, remote RedisClient, pubsub PubSub) *TieredClient {
+	c := &TieredClient{
+		local:  local,
+		remote: remote,
+		pubsub: pubsub,
+		id:     instanceID(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if pubsub == nil {
+		close(c.done)
+		return c
+	}
+	c.sub = pubsub.Subscribe(context.Background(), EvictionChannel)
+	go c.listen()
+	return c
+}
+
+func (c *TieredClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	if value, ok := c.local.Get(key); ok {
+		return redis.NewStringResult(value, nil)
+	}
+
+	evictions := c.evictions.Load()
+	cmd := c.remote.Get(ctx, key)
+	if value, err := cmd.Result(); err == nil {
+		// Keep the local copy from outliving the remote one, e.g. for expiring links.
+		ttl, err := c.remote.PTTL(ctx, key).Result()
+		if err == nil && c.evictions.Load() == evictions {
+			c.local.Set(key, value, ttl)
+		}
+	}
+	return cmd
+}
+
+func (c *TieredClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	c.local.Set(key, toString(value), expiration)
+	cmd := c.remote.Set(ctx, key, value, expiration)
+	c.publish(ctx, key)
+	return cmd
+}
+
+func (c *TieredClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	c.evict(keys)
+	cmd := c.remote.Del(ctx, keys...)
+	c.publish(ctx, keys...)
+	return cmd
+}
+
+func (c *TieredClient) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	return c.remote.PTTL(ctx, key)
+}
+
+// HIncrBy, HGet and Eval only go to the remote client, as they are used for
+// shared counters rather than cached values.
+func (c *TieredClient) HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd {
+	return c.remote.HIncrBy(ctx, key, field, incr)
+}
+
+func (c *TieredClient) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	return c.remote.HGet(ctx, key, field)
+}
+
+func (c *TieredClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return c.remote.Eval(ctx, script, keys, args...)
+}
+
+// Pipelined only goes to the remote client. Use it for keys that aren't cached
+// locally yet, such as those of new links, so no stale local copy is left behind.
+func (c *TieredClient) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return c.remote.Pipelined(ctx, fn)
+}
+
+func (c *TieredClient) Stats() LRUStats {
+	return c.local.Stats()
+}
+
+// Close stops receiving evictions from other instances.
+func (c *TieredClient) Close() error {
+	if c.sub == nil {
+		return nil
+	}
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	err := c.sub.Close()
+	<-c.done
+	return err
+}
+
+// evict drops the local copies of keys.
+func (c *TieredClient) evict(keys []string) {
+	c.evictions.Add(1)
+	for _, key := range keys {
+		c.local.Delete(key)
+	}
+}
+
+// publish tells the other instances to drop their copies of keys. It is called
+// after the remote write, so they can't read the old value again.
+func (c *TieredClient) publish(ctx context.Context, keys ...string) {
+	if c.pubsub == nil {
+		return
+	}
+	message := c.id + "\n" + strings.Join(keys, "\n")
+	if err := c.pubsub.Publish(ctx, EvictionChannel, message).Err(); err != nil {
+		slog.Warn("Failed to publish local cache evictions", "keys", keys, "error", err)
+	}
+}
+
+func (c *TieredClient) listen() {
+	defer close(c.done)
+
+	for {
+		msg, err := c.sub.Receive(context.Background())
+		if err != nil {
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			// Evictions published meanwhile are lost.
+			c.purge()
+			select {
+			case <-time.After(resubscribeDelay):
+				continue
+			case <-c.stop:
+				return
+			}
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Evictions published before the (re)subscription are lost.
+			c.purge()
+		case *redis.Message:
+			sender, keys, _ := strings.Cut(msg.Payload, "\n")
+			if sender != c.id {
+				c.evict(strings.Split(keys, "\n"))
+			}
+		}
+	}
+}
+
+func (c *TieredClient) purge() {
+	c.evictions.Add(1)
+	c.local.Purge()
+}
+
+// instanceID returns a random ID for a TieredClient.
+func instanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// toString formats a value the way Redis stores it.
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,228 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OverflowPolicy decides what Add does when the buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowDrop discards the increment so redirects never wait on the database.
+	OverflowDrop OverflowPolicy = "drop"
+	// OverflowBlock makes Add wait for buffer space or the request to end.
+	OverflowBlock OverflowPolicy = "block"
+)
+
+var ErrClosed = errors.New("aggregator closed")
+
+var tracer = otel.Tracer("shortlink-go/internal/counter")
+
+// maxLinks bounds the request spans a flush span links to. Busy flushes link
+// to the first ones only.
+const maxLinks = 128
+
+// Flusher persists a batch of access count increments keyed by URL ID.
+// repository.URLRepository implements it.
+type Flusher interface {
+	IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error
+}
+
+type Options struct {
+	FlushInterval time.Duration // Flush at least this often while increments are pending.
+	FlushSize     int           // Flush early once this many distinct IDs are pending.
+	BufferSize    int           // Increments queued before the overflow policy applies.
+	Overflow      OverflowPolicy
+}
+
+// Aggregator collects access count increments in memory and writes them in
+// batches, replacing a database write per redirect with one per flush.
+type Aggregator struct {
+	flusher Flusher
+	opts    Options
+	queue   chan access
+	pending map[int64]int64 // Owned by the run goroutine.
+	links   []trace.Link    // Request spans of the pending increments, owned by the run goroutine.
+
+	mu       sync.RWMutex // Guards closed against concurrent Adds.
+	closed   bool
+	closeCtx context.Context // Bounds the final flush, set before the queue is closed.
+	done     chan struct{}
+	dropped  atomic.Int64
+	backlog  atomic.Int64 // Increments accepted but not yet flushed.
+
+	unflushedMu sync.Mutex
+	unflushed   map[int64]int64 // Backlog by URL ID.
+}
+
+// NewAggregator starts an aggregator flushing to flusher. Call Close to stop it.
+func NewAggregator(flusher Flusher, opts Options) *Aggregator {
+	a := &Aggregator{
+		flusher:   flusher,
+		opts:      opts,
+		queue:     make(chan access, opts.BufferSize),
+		pending:   make(map[int64]int64),
+		done:      make(chan struct{}),
+		unflushed: make(map[int64]int64),
+	}
+	go a.run()
+	return a
+}
+
+// access is one increment, with the span of the request that made it.
+type access struct {
+	id   int64
+	span trace.SpanContext
+}
+
+// Add records one access of the URL with the given ID. The span that writes it
+// links to the span of ctx.
+func (a *Aggregator) Add(ctx context.Context, id int64) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+	acc := access{id: id, span: trace.SpanContextFromContext(ctx)}
+
+	if a.opts.Overflow == OverflowBlock {
+		select {
+		case a.queue <- acc:
+			a.accepted(id)
+			return nil
+		case <-ctx.Done():
+			a.dropped.Add(1)
+			return ctx.Err()
+		}
+	}
+
+	select {
+	case a.queue <- acc:
+		a.accepted(id)
+	default:
+		a.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped returns how many increments were discarded because the buffer was full.
+func (a *Aggregator) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// accepted counts an increment queued for the URL with the given ID.
+func (a *Aggregator) accepted(id int64) {
+	a.backlog.Add(1)
+	a.unflushedMu.Lock()
+	a.unflushed[id]++
+	a.unflushedMu.Unlock()
+}
+
+// Pending returns the accesses of the URL accepted but not yet written to the database.
+func (a *Aggregator) Pending(ctx context.Context, id int64) (int64, error) {
+	a.unflushedMu.Lock()
+	defer a.unflushedMu.Unlock()
+	return a.unflushed[id], nil
+}
+
+// Backlog returns how many increments were accepted but not yet written to the database.
+func (a *Aggregator) Backlog(ctx context.Context) (int64, error) {
+	return a.backlog.Load(), nil
+}
+
+// Close stops accepting increments and flushes everything still buffered,
+// giving up when ctx is done.
+func (a *Aggregator) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		a.closeCtx = ctx
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Aggregator) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case acc, ok := <-a.queue:
+			if !ok {
+				a.flush(a.closeCtx)
+				return
+			}
+			a.pending[acc.id]++
+			if acc.span.IsValid() && len(a.links) < maxLinks {
+				a.links = append(a.links, trace.Link{SpanContext: acc.span})
+			}
+			if len(a.pending) >= a.opts.FlushSize {
+				a.flush(context.Background())
+			}
+		case <-ticker.C:
+			a.flush(context.Background())
+		}
+	}
+}
+
+// flush writes the pending increments, giving up after 10s or when parent is done.
+func (a *Aggregator) flush(parent context.Context) {
+	if len(a.pending) == 0 {
+		return
+	}
+
+	batch := a.pending
+	a.pending = make(map[int64]int64, len(batch))
+
+	// The flush serves many requests, so it starts its own trace linked to theirs.
+	ctx, span := tracer.Start(parent, "counter.flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(a.links...),
+		trace.WithAttributes(attribute.Int("shortlink.flush.urls", len(batch))),
+	)
+	defer span.End()
+	a.links = nil
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := a.flusher.IncrementAccessCounts(ctx, batch); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.Error("Failed to flush access counts, retrying on next flush", "urls", len(batch), "error", err)
+		// Keep the counts for the next flush. This is bounded by the number of distinct IDs.
+		for id, n := range batch {
+			a.pending[id] += n
+		}
+		return
+	}
+	var flushed int64
+	a.unflushedMu.Lock()
+	for id, n := range batch {
+		flushed += n
+		if a.unflushed[id] -= n; a.unflushed[id] <= 0 {
+			delete(a.unflushed, id)
+		}
+	}
+	a.unflushedMu.Unlock()
+	a.backlog.Add(-flushed)
+}
@@ -0,0 +1,190 @@
+package counter_test
+
+import (
+	"context"
+	"shortlink-go/internal/counter"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type recordingFlusher struct {
+	mu      sync.Mutex
+	batches []map[int64]int64
+}
+
+func (f *recordingFlusher) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, counts)
+	return nil
+}
+
+func (f *recordingFlusher) totals() map[int64]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	totals := make(map[int64]int64)
+	for _, batch := range f.batches {
+		for id, n := range batch {
+			totals[id] += n
+		}
+	}
+	return totals
+}
+
+func TestAggregator_FlushesOnClose(t *testing.T) {
+	flusher := &recordingFlusher{}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		BufferSize:    100,
+		Overflow:      counter.OverflowBlock,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, a.Add(ctx, 1))
+	}
+	assert.NoError(t, a.Add(ctx, 2))
+	assert.NoError(t, a.Close(ctx))
+
+	assert.Equal(t, map[int64]int64{1: 3, 2: 1}, flusher.totals())
+	assert.Len(t, flusher.batches, 1)
+	assert.ErrorIs(t, a.Add(ctx, 1), counter.ErrClosed)
+}
+
+func TestAggregator_FlushesOnSize(t *testing.T) {
+	flusher := &recordingFlusher{}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: time.Hour,
+		FlushSize:     2,
+		BufferSize:    100,
+		Overflow:      counter.OverflowBlock,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, a.Add(ctx, 1))
+	assert.NoError(t, a.Add(ctx, 2))
+	assert.Eventually(t, func() bool { return len(flusher.totals()) == 2 }, time.Second, time.Millisecond)
+	assert.NoError(t, a.Close(ctx))
+}
+
+func TestAggregator_FlushesOnInterval(t *testing.T) {
+	flusher := &recordingFlusher{}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: 10 * time.Millisecond,
+		FlushSize:     100,
+		BufferSize:    100,
+		Overflow:      counter.OverflowDrop,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, a.Add(ctx, 1))
+	assert.Eventually(t, func() bool { return flusher.totals()[1] == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, a.Close(ctx))
+}
+
+func TestAggregator_Backlog(t *testing.T) {
+	flusher := &recordingFlusher{}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		BufferSize:    100,
+		Overflow:      counter.OverflowBlock,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, a.Add(ctx, 1))
+	assert.NoError(t, a.Add(ctx, 1))
+	assert.NoError(t, a.Add(ctx, 2))
+	backlog, err := a.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), backlog)
+	pending, err := a.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pending)
+
+	assert.NoError(t, a.Close(ctx))
+	backlog, err = a.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), backlog)
+	pending, err = a.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+}
+
+var (
+	spans     = tracetest.NewInMemoryExporter()
+	provider  = sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	setupOnce sync.Once
+)
+
+func TestAggregator_FlushLinksToRequests(t *testing.T) {
+	// Tracers obtained before keep using the first provider set.
+	setupOnce.Do(func() { otel.SetTracerProvider(provider) })
+	spans.Reset()
+
+	flusher := &recordingFlusher{}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		BufferSize:    100,
+		Overflow:      counter.OverflowBlock,
+	})
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	assert.NoError(t, a.Add(ctx, 1))
+	assert.NoError(t, a.Add(context.Background(), 2))
+	request.End()
+	assert.NoError(t, a.Close(context.Background()))
+
+	var flush tracetest.SpanStub
+	for _, span := range spans.GetSpans() {
+		if span.Name == "counter.flush" {
+			flush = span
+		}
+	}
+	require.Equal(t, "counter.flush", flush.Name)
+	assert.False(t, flush.Parent.IsValid())
+	require.Len(t, flush.Links, 1)
+	assert.Equal(t, request.SpanContext(), flush.Links[0].SpanContext)
+}
+
+// blockingFlusher waits for the context of each flush to be done.
+type blockingFlusher struct {
+	done chan error
+}
+
+func (f *blockingFlusher) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	<-ctx.Done()
+	f.done <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestAggregator_CloseBoundsFinalFlush(t *testing.T) {
+	flusher := &blockingFlusher{done: make(chan error, 1)}
+	a := counter.NewAggregator(flusher, counter.Options{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		BufferSize:    100,
+		Overflow:      counter.OverflowBlock,
+	})
+	assert.NoError(t, a.Add(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, a.Close(ctx), context.DeadlineExceeded)
+
+	// The final flush gives up with Close rather than after its own timeout.
+	select {
+	case err := <-flusher.done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("final flush outlived the Close deadline")
+	}
+}
@@ -0,0 +1,224 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"shortlink-go/internal/cache"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisClicksKey is the Redis hash holding access counts not yet synced to the
+// database, keyed by URL ID. The "-" keeps it apart from the "shortlink:<code>"
+// cache keys, as generated short links are purely alphanumeric.
+const RedisClicksKey = "shortlink:access-counts"
+
+// takeClicksScript atomically reads and clears the pending counts, so every
+// increment is moved to the database by exactly one syncer across instances.
+const takeClicksScript = `
+local counts = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return counts
+`
+
+// sumClicksScript returns the total of the pending counts.
+const sumClicksScript = `
+local total = 0
+for _, n in ipairs(redis.call('HVALS', KEYS[1])) do
+	total = total + tonumber(n)
+end
+return total
+`
+
+// RedisCounter counts accesses with HINCRBY at redirect time and periodically
+// moves the accumulated deltas into the database. While a circuit breaker skips
+// Redis, accesses go to an in-memory fallback that writes them to the database
+// itself.
+type RedisCounter struct {
+	client   cache.RedisClient
+	flusher  Flusher
+	interval time.Duration
+	fallback *Aggregator // Nil to fail Adds while Redis is skipped.
+
+	closeOnce sync.Once
+	closeCtx  context.Context // Bounds the final sync, set before stop is closed.
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewRedisCounter starts a counter syncing to flusher every interval. Call Close
+// to stop it, which also closes fallback.
+func NewRedisCounter(client cache.RedisClient, flusher Flusher, interval time.Duration, fallback *Aggregator) *RedisCounter {
+	c := &RedisCounter{
+		client:   client,
+		flusher:  flusher,
+		interval: interval,
+		fallback: fallback,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Add records one access of the URL with the given ID.
+func (c *RedisCounter) Add(ctx context.Context, id int64) error {
+	err := c.client.HIncrBy(ctx, RedisClicksKey, strconv.FormatInt(id, 10), 1).Err()
+	// Other errors may come after Redis applied the increment, so only skipped
+	// increments are safe to count again.
+	if errors.Is(err, cache.ErrCircuitOpen) && c.fallback != nil {
+		return c.fallback.Add(ctx, id)
+	}
+	return err
+}
+
+// Pending returns the accesses of the URL not yet synced to the database.
+func (c *RedisCounter) Pending(ctx context.Context, id int64) (int64, error) {
+	var fallback int64
+	if c.fallback != nil {
+		fallback, _ = c.fallback.Pending(ctx, id)
+	}
+	pending, err := c.client.HGet(ctx, RedisClicksKey, strconv.FormatInt(id, 10)).Int64()
+	if errors.Is(err, redis.Nil) || errors.Is(err, cache.ErrCircuitOpen) && c.fallback != nil {
+		return fallback, nil // Counts in Redis can't be read while it is skipped.
+	}
+	if err != nil {
+		return 0, err
+	}
+	return pending + fallback, nil
+}
+
+// Backlog returns the accesses of all URLs not yet synced to the database.
+func (c *RedisCounter) Backlog(ctx context.Context) (int64, error) {
+	var fallback int64
+	if c.fallback != nil {
+		fallback, _ = c.fallback.Backlog(ctx)
+	}
+	n, err := c.client.Eval(ctx, sumClicksScript, []string{RedisClicksKey}).Int64()
+	if errors.Is(err, cache.ErrCircuitOpen) && c.fallback != nil {
+		return fallback, nil // The counts in Redis can't be read until it is back.
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n + fallback, nil
+}
+
+// Dropped returns how many accesses the fallback discarded because its buffer
+// was full.
+func (c *RedisCounter) Dropped() int64 {
+	if c.fallback == nil {
+		return 0
+	}
+	return c.fallback.Dropped()
+}
+
+// Close stops the periodic sync and runs a final one, then closes the fallback,
+// giving up when ctx is done.
+func (c *RedisCounter) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeCtx = ctx
+		close(c.stop)
+	})
+
+	var err error
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if c.fallback != nil {
+		if fallbackErr := c.fallback.Close(ctx); err == nil {
+			err = fallbackErr
+		}
+	}
+	return err
+}
+
+func (c *RedisCounter) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.sync(context.Background())
+		case <-c.stop:
+			c.sync(c.closeCtx)
+			return
+		}
+	}
+}
+
+// sync moves the pending counts to the database, giving up after 10s or when
+// parent is done.
+func (c *RedisCounter) sync(parent context.Context) {
+	// Increments reach the syncer through Redis, possibly from other instances,
+	// so its trace can't be linked to their requests.
+	ctx, span := tracer.Start(parent, "counter.sync", trace.WithNewRoot())
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	counts, err := c.take(ctx)
+	if errors.Is(err, cache.ErrCircuitOpen) {
+		return // The counts wait in Redis until it is back.
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.Error("Failed to read access counts from Redis", "error", err)
+		return
+	}
+	if len(counts) == 0 {
+		return
+	}
+	span.SetAttributes(attribute.Int("shortlink.flush.urls", len(counts)))
+
+	if err := c.flusher.IncrementAccessCounts(ctx, counts); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.Error("Failed to sync access counts, returning them to Redis", "urls", len(counts), "error", err)
+		// The sync may have failed because parent is done, which mustn't keep
+		// the counts from going back.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		for id, n := range counts {
+			if err := c.client.HIncrBy(ctx, RedisClicksKey, strconv.FormatInt(id, 10), n).Err(); err != nil {
+				slog.Error("Lost accesses", "id", id, "count", n, "error", err)
+			}
+		}
+	}
+}
+
+// take removes and returns all pending counts.
+func (c *RedisCounter) take(ctx context.Context) (map[int64]int64, error) {
+	fields, err := c.client.Eval(ctx, takeClicksScript, []string{RedisClicksKey}).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int64, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		id, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL ID %q: %w", fields[i], err)
+		}
+		n, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access count %q: %w", fields[i+1], err)
+		}
+		counts[id] += n
+	}
+	return counts, nil
+}
@@ -0,0 +1,115 @@
+package counter_test
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/cache"
+	"shortlink-go/internal/counter"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingFlusher struct{}
+
+func (failingFlusher) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	return errors.New("database unavailable")
+}
+
+func newRedis(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRedisCounter_SyncsOnClose(t *testing.T) {
+	client := newRedis(t)
+	flusher := &recordingFlusher{}
+	c := counter.NewRedisCounter(client, flusher, time.Hour, nil)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Add(ctx, 1))
+	assert.NoError(t, c.Add(ctx, 1))
+	assert.NoError(t, c.Add(ctx, 2))
+
+	pending, err := c.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pending)
+
+	assert.NoError(t, c.Close(ctx))
+	assert.Equal(t, map[int64]int64{1: 2, 2: 1}, flusher.totals())
+
+	pending, err = c.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+}
+
+func TestRedisCounter_KeepsCountsWhenSyncFails(t *testing.T) {
+	client := newRedis(t)
+	c := counter.NewRedisCounter(client, failingFlusher{}, time.Hour, nil)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Add(ctx, 7))
+	assert.NoError(t, c.Close(ctx))
+
+	pending, err := c.Pending(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+}
+
+func TestRedisCounter_Backlog(t *testing.T) {
+	client := newRedis(t)
+	flusher := &recordingFlusher{}
+	c := counter.NewRedisCounter(client, flusher, time.Hour, nil)
+	ctx := context.Background()
+
+	backlog, err := c.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), backlog)
+
+	assert.NoError(t, c.Add(ctx, 1))
+	assert.NoError(t, c.Add(ctx, 1))
+	assert.NoError(t, c.Add(ctx, 2))
+	backlog, err = c.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), backlog)
+
+	assert.NoError(t, c.Close(ctx))
+	backlog, err = c.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), backlog)
+}
+
+func TestRedisCounter_FallsBackWhileBreakerOpen(t *testing.T) {
+	client := newRedis(t)
+	b := cache.NewBreaker(client, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}, cache.BreakerOptions{Threshold: 2, ProbeInterval: time.Hour})
+	t.Cleanup(func() { b.Close() })
+	flusher := &recordingFlusher{}
+	fallback := counter.NewAggregator(flusher, counter.Options{FlushInterval: time.Hour, FlushSize: 100, BufferSize: 10})
+	c := counter.NewRedisCounter(b, flusher, time.Hour, fallback)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Add(ctx, 1))
+	b.Trip()
+	assert.NoError(t, c.Add(ctx, 1))
+	assert.NoError(t, c.Add(ctx, 2))
+
+	backlog, err := c.Backlog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), backlog)
+	pending, err := c.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pending) // Only the count buffered in memory can be read.
+
+	assert.NoError(t, c.Close(ctx))
+	assert.Equal(t, map[int64]int64{1: 1, 2: 1}, flusher.totals())
+	pending, err = client.HGet(ctx, counter.RedisClicksKey, "1").Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+}
@@ -1,29 +1,73 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"log/slog"
 	"shortlink-go/config"
+	"shortlink-go/internal/logging"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
 )
 
+// NewDB opens the database of the configured storage driver.
 func NewDB(cfg *config.Config) *sql.DB {
-	dbURL := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	var driverName, dsn string
+	var dbSystem attribute.KeyValue
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		driverName = "pgx"
+		dbSystem = semconv.DBSystemPostgreSQL
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	case config.StorageSQLite:
+		// WAL lets redirects read while a link is being written, and the busy
+		// timeout makes concurrent writers wait for each other instead of failing.
+		driverName = "sqlite"
+		dbSystem = semconv.DBSystemSqlite
+		dsn = fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", cfg.SQLitePath)
+	default:
+		logging.Fatal("Storage driver has no database", "driver", cfg.StorageDriver)
+	}
 
-	db, err := sql.Open("pgx", dbURL)
+	// Every query gets a span under the span of its context. Queries outside of
+	// a trace, such as migrations and pings, aren't traced.
+	db, err := otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(dbSystem),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
 	if err != nil {
-		log.Fatalf("Error opening database: %v\n", err)
+		logging.Fatal("Failed to open database", "error", err)
 	}
 
 	// Check the connection
 	err = db.Ping()
 	if err != nil {
-		log.Fatalf("Error pinging database: %v\n", err)
+		logging.Fatal("Failed to connect to the database", "error", err)
+	}
+
+	slog.Info("Connected to the database")
+
+	if cfg.DBAutoMigrate {
+		applied, err := MigrateUp(context.Background(), db, cfg.StorageDriver)
+		if err != nil {
+			logging.Fatal("Failed to migrate database", "error", err)
+		}
+		slog.Info("Database schema is up to date", "applied", applied)
 	}
 
-	log.Println("Connected to the database successfully.")
 	return db
 }
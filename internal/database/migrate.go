@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"shortlink-go/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID is the key of the Postgres advisory lock held while migrating,
+// so instances starting together during a rolling deploy apply each migration once.
+const migrationLockID = 7207361
+
+// migrationDialect holds what differs between the storage drivers when migrating.
+type migrationDialect struct {
+	dir          string
+	versionTable string
+	// lock serializes migrations across instances, if the driver needs it.
+	lock func(ctx context.Context, conn *sql.Conn) (unlock func(), err error)
+}
+
+var migrationDialects = map[config.StorageDriver]migrationDialect{
+	config.StoragePostgres: {
+		dir: "migrations/postgres",
+		versionTable: `CREATE TABLE IF NOT EXISTS schema_versions (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+		lock: advisoryLock,
+	},
+	config.StorageSQLite: {
+		dir: "migrations/sqlite",
+		versionTable: `CREATE TABLE IF NOT EXISTS schema_versions (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// SQLite is a single local file, so there are no concurrent deploys to guard against.
+		lock: func(ctx context.Context, conn *sql.Conn) (func(), error) { return func() {}, nil },
+	},
+}
+
+func dialectFor(driver config.StorageDriver) (migrationDialect, error) {
+	d, ok := migrationDialects[driver]
+	if !ok {
+		return migrationDialect{}, fmt.Errorf("storage driver %q has no migrations", driver)
+	}
+	return d, nil
+}
+
+// Migration is a versioned schema change loaded from migrations/<driver>/<version>_<name>.{up,down}.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a known migration and when it was applied, if ever.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// MigrateUp applies all pending migrations in version order and returns how many were applied.
+func MigrateUp(ctx context.Context, db *sql.DB, driver config.StorageDriver) (int, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return 0, err
+	}
+	migrations, err := loadMigrations(migrationsFS, d.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, d, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, d)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_versions (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrateDown rolls back the given number of most recently applied migrations
+// and returns how many were rolled back.
+func MigrateDown(ctx context.Context, db *sql.DB, driver config.StorageDriver, steps int) (int, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return 0, err
+	}
+	migrations, err := loadMigrations(migrationsFS, d.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, d, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, d)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_versions WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rolling back migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Rolled back migration", "version", m.Version, "name", m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists every known migration and whether it has been applied.
+func Status(ctx context.Context, db *sql.DB, driver config.StorageDriver) ([]MigrationStatus, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(migrationsFS, d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := appliedVersions(ctx, conn, d)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the dialect's migration lock.
+func withMigrationLock(ctx context.Context, db *sql.DB, d migrationDialect, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := d.lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer unlock()
+
+	return fn(conn)
+}
+
+func advisoryLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}, nil
+}
+
+// appliedVersions creates the version table if needed and returns the applied versions.
+func appliedVersions(ctx context.Context, conn *sql.Conn, d migrationDialect) (map[int64]time.Time, error) {
+	_, err := conn.ExecContext(ctx, d.versionTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_versions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadMigrations reads the up/down pairs in dir from fsys, sorted by version.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", base)
+		}
+		versionStr, name, ok := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", base)
+		}
+
+		contents, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS urls (
+    id BIGSERIAL PRIMARY KEY,
+    long_url TEXT NOT NULL,
+    access_count BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE urls DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS owner_key_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- Only a SHA-256 hash of each key is stored.
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+-- The key that created a link, NULL for links created without one.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_key_id BIGINT REFERENCES api_keys (id);
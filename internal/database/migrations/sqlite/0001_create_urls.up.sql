@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS urls (
+    -- AUTOINCREMENT keeps IDs from being reused, as short links are derived from them.
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    long_url TEXT NOT NULL,
+    alias TEXT UNIQUE,
+    access_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP
+);
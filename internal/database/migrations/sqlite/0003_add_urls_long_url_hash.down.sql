@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS urls_long_url_hash_idx;
+ALTER TABLE urls DROP COLUMN long_url_hash;
@@ -0,0 +1,4 @@
+ALTER TABLE urls ADD COLUMN long_url_hash TEXT;
+
+-- Only plain links, without alias or expiry, are reused when deduplicating.
+CREATE INDEX IF NOT EXISTS urls_long_url_hash_idx ON urls (long_url_hash) WHERE alias IS NULL AND expires_at IS NULL;
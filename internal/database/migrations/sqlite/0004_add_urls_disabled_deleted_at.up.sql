@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- Deleted links are kept, so their IDs and aliases are never reused.
+ALTER TABLE urls ADD COLUMN deleted_at TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN updated_at;
+ALTER TABLE urls DROP COLUMN version;
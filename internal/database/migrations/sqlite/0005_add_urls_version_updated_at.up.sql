@@ -0,0 +1,3 @@
+-- version is bumped on every edit and served as the ETag of a link.
+ALTER TABLE urls ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE urls ADD COLUMN updated_at TIMESTAMP;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    -- Only a SHA-256 hash of each key is stored.
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+-- The key that created a link, NULL for links created without one. Without a
+-- REFERENCES clause, as SQLite can't drop columns used in foreign keys.
+ALTER TABLE urls ADD COLUMN owner_key_id INTEGER;
@@ -1,52 +1,130 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"shortlink-go/internal/health"
+	"shortlink-go/internal/model"
 	"shortlink-go/internal/service"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type Handler struct {
-	service service.IService
+	service   service.IService
+	readiness Readiness
 }
 
-func NewHandler(srv service.IService) *Handler {
+// Readiness reports whether the service can take traffic.
+type Readiness interface {
+	Ready(ctx context.Context) health.Report
+}
+
+// NewHandler returns a handler of the routes. A nil readiness is always ready.
+func NewHandler(srv service.IService, readiness Readiness) *Handler {
 	return &Handler{
-		service: srv,
+		service:   srv,
+		readiness: readiness,
 	}
 }
 
-func (h *Handler) RegisterRoutes(r *gin.Engine) {
-	r.GET("/health", h.HealthCheck)
-	r.POST("/create", h.CreateShortLink)
-	r.GET("/:shortLink", h.RedirectToLongURL)
-	r.GET("/stats/:shortLink", h.GetStats)
+// Middleware holds optional middleware for groups of routes. Nil entries are skipped.
+type Middleware struct {
+	// Auth runs for every route except the probes and redirects.
+	Auth gin.HandlerFunc
+	// RedirectLimit, CreateLimit and APILimit limit the request rate of
+	// redirects, link creation and the other routes. They run after Auth.
+	RedirectLimit gin.HandlerFunc
+	CreateLimit   gin.HandlerFunc
+	APILimit      gin.HandlerFunc
+}
+
+func (h *Handler) RegisterRoutes(r *gin.Engine, mw Middleware) {
+	// Handlers pass the gin context to the service, which must see the values
+	// middleware stored in the request context.
+	r.ContextWithFallback = true
+
+	r.GET("/health", h.Live)
+	r.GET("/livez", h.Live)
+	r.GET("/readyz", h.Ready)
+	r.GET("/:shortLink", handlers(mw.RedirectLimit, h.RedirectToLongURL)...)
+
+	authenticated := r.Group("", handlers(mw.Auth)...)
+	create := authenticated.Group("", handlers(mw.CreateLimit)...)
+	create.POST("/create", h.CreateShortLink)
+	create.POST("/create/batch", h.CreateShortLinks)
+
+	api := authenticated.Group("", handlers(mw.APILimit)...)
+	api.PATCH("/links/:shortLink", h.UpdateLink)
+	api.DELETE("/links/:shortLink", h.DeleteLink)
+	api.POST("/links/:shortLink/disable", h.DisableLink)
+	api.POST("/links/:shortLink/enable", h.EnableLink)
+	api.GET("/stats/:shortLink", h.GetStats)
+	api.GET("/stats/:shortLink/timeseries", h.GetTimeSeries)
 }
 
-// HealthCheck shows the status of the service
-// @Summary Show service health status
-// @Description Get the health status of the service
+// handlers returns the non-nil handlers in order.
+func handlers(hs ...gin.HandlerFunc) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	for _, h := range hs {
+		if h != nil {
+			chain = append(chain, h)
+		}
+	}
+	return chain
+}
+
+// Live shows that the process is up
+// @Summary Liveness probe
+// @Description Always succeeds while the server can answer. It doesn't check dependencies, so an outage of the database doesn't get the service restarted. /health is kept for existing checks.
 // @Tags health
-// @Accept  json
 // @Produce  json
 // @Success 200 {object} map[string]interface{}
+// @Router /livez [get]
 // @Router /health [get]
-func (h *Handler) HealthCheck(ctx *gin.Context) {
+func (h *Handler) Live(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// Ready shows whether the service can take traffic
+// @Summary Readiness probe
+// @Description Pings the database and Redis and reports the status and latency of each. Fails while the database is down and once the service is shutting down. Without Redis the service is "degraded" but still ready.
+// @Tags health
+// @Produce  json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func (h *Handler) Ready(ctx *gin.Context) {
+	report := health.Report{Status: health.StatusReady, Checks: map[string]health.Result{}}
+	if h.readiness != nil {
+		report = h.readiness.Ready(ctx)
+	}
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
+
 // CreateShortLink creates a new short link
 // @Summary Create a new short link
-// @Description Create a new short link from a given long URL
+// @Description Create a new short link from a given long URL, optionally with a custom alias and an expiry
 // @Tags links
 // @Accept  json
 // @Produce  json
 // @Param   request  body      CreateLinkRequest true  "Create Link Request"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
 // @Router /create [post]
 func (h *Handler) CreateShortLink(ctx *gin.Context) {
 	var request CreateLinkRequest
@@ -61,14 +139,103 @@ func (h *Handler) CreateShortLink(ctx *gin.Context) {
 		return
 	}
 
-	shortLink, err := h.service.CreateShortLink(ctx, request.LongURL)
+	opts, errMsg := linkOptions(request)
+	if errMsg != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	shortLink, err := h.service.CreateShortLink(ctx, request.LongURL, opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short link"})
+		status, errMsg := createError(err)
+		if status == http.StatusInternalServerError {
+			ctx.Error(err)
+		}
+		ctx.JSON(status, gin.H{"error": errMsg})
 		return
 	}
 	ctx.JSON(http.StatusCreated, gin.H{"shortLink": shortLink})
 }
 
+// CreateShortLinks creates short links in bulk
+// @Summary Create short links in bulk
+// @Description Create up to 1000 short links at once. Each link is validated and created independently, and the response holds one result per link in request order. Returns 201 if all links were created and 207 if any failed.
+// @Tags links
+// @Accept  json
+// @Produce  json
+// @Param   request  body      CreateBatchRequest true  "Create Batch Request"
+// @Success 201 {object} CreateBatchResponse
+// @Success 207 {object} CreateBatchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /create/batch [post]
+func (h *Handler) CreateShortLinks(ctx *gin.Context) {
+	var request CreateBatchRequest
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
+		return
+	}
+	if len(request.Links) > service.MaxBatchSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch can hold at most %d links", service.MaxBatchSize)})
+		return
+	}
+
+	response := CreateBatchResponse{Results: make([]BatchLinkResult, len(request.Links))}
+	links := make([]service.BatchLink, 0, len(request.Links))
+	indexes := make([]int, 0, len(request.Links)) // Position in the request of each entry of links.
+	for i, item := range request.Links {
+		response.Results[i] = BatchLinkResult{Index: i, Status: http.StatusBadRequest}
+		// Items aren't validated by BindJSON, so that one bad item doesn't fail the batch.
+		if err := binding.Validator.ValidateStruct(&item); err != nil {
+			response.Results[i].Error = "Bad request"
+			continue
+		}
+		if _, err := url.ParseRequestURI(item.LongURL); err != nil {
+			response.Results[i].Error = "Invalid URL"
+			continue
+		}
+		opts, errMsg := linkOptions(item)
+		if errMsg != "" {
+			response.Results[i].Error = errMsg
+			continue
+		}
+		links = append(links, service.BatchLink{LongURL: item.LongURL, Options: opts})
+		indexes = append(indexes, i)
+	}
+
+	results, err := h.service.CreateShortLinks(ctx, links)
+	if err != nil {
+		ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short links"})
+		return
+	}
+	for j, result := range results {
+		item := &response.Results[indexes[j]]
+		if result.Err != nil {
+			item.Status, item.Error = createError(result.Err)
+			continue
+		}
+		item.Status = http.StatusCreated
+		item.ShortLink = result.ShortLink
+	}
+
+	for _, item := range response.Results {
+		if item.Status == http.StatusCreated {
+			response.Created++
+		} else {
+			response.Failed++
+		}
+	}
+	status := http.StatusCreated
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, response)
+}
+
 // RedirectToLongURL redirects to the original URL based on the short link provided
 // @Summary Redirect to the original URL
 // @Description Redirects the request to the original long URL based on the provided short link
@@ -78,22 +245,154 @@ func (h *Handler) CreateShortLink(ctx *gin.Context) {
 // @Param   shortLink  path      string  true  "Short Link"
 // @Success 307 {header} string Location "Location header with the original URL"
 // @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /{shortLink} [get]
 func (h *Handler) RedirectToLongURL(ctx *gin.Context) {
 	shortLink := ctx.Param("shortLink")
-	longURL, err := h.service.GetLongURL(ctx, shortLink)
+	longURL, id, err := h.service.GetLongURL(ctx, shortLink)
 	if err != nil {
-		if err == service.ErrShortLinkNotFound {
+		switch err {
+		case service.ErrShortLinkNotFound:
 			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
-		} else {
+		case service.ErrShortLinkExpired:
+			ctx.JSON(http.StatusGone, gin.H{"error": "Short link expired"})
+		case service.ErrShortLinkDisabled:
+			ctx.JSON(http.StatusGone, gin.H{"error": "Short link disabled"})
+		default:
+			ctx.Error(err)
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redirect to long URL"})
 		}
 		return
 	}
+
+	h.service.RecordClick(ctx, id, model.Click{
+		ClickedAt:      time.Now().UTC(),
+		Referrer:       ctx.Request.Referer(),
+		UserAgent:      ctx.Request.UserAgent(),
+		AcceptLanguage: ctx.GetHeader("Accept-Language"),
+	}, ctx.ClientIP())
+
 	ctx.Redirect(http.StatusTemporaryRedirect, longURL)
 }
 
+// UpdateLink changes the long URL of a short link
+// @Summary Change the destination of a short link
+// @Description Point a short link to a new long URL. Send the ETag of the link, as returned by this endpoint or the stats endpoint, in If-Match to fail with 412 if someone else changed the link in the meantime.
+// @Tags links
+// @Accept  json
+// @Produce  json
+// @Param   shortLink  path      string             true   "Short Link"
+// @Param   If-Match   header    string             false  "ETag of the link version being edited"
+// @Param   request    body      UpdateLinkRequest  true   "Update Link Request"
+// @Success 200 {object} GetStatsResponse
+// @Header  200 {string} ETag "Version of the updated link"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /links/{shortLink} [patch]
+func (h *Handler) UpdateLink(ctx *gin.Context) {
+	shortLink := ctx.Param("shortLink")
+
+	var request UpdateLinkRequest
+	if err := ctx.BindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
+		return
+	}
+	if _, err := url.ParseRequestURI(request.LongURL); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
+		return
+	}
+
+	version, ok := parseIfMatch(ctx.GetHeader("If-Match"))
+	if !ok {
+		ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match doesn't match the current version"})
+		return
+	}
+
+	link, err := h.service.UpdateLongURL(ctx, shortLink, request.LongURL, version)
+	if err != nil {
+		switch err {
+		case service.ErrShortLinkNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
+		case service.ErrVersionMismatch:
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match doesn't match the current version"})
+		default:
+			ctx.Error(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update short link"})
+		}
+		return
+	}
+	ctx.Header("ETag", etag(link.Version))
+	ctx.JSON(http.StatusOK, statsResponse(shortLink, link))
+}
+
+// DeleteLink deletes a short link
+// @Summary Delete a short link
+// @Description Delete a short link so it stops redirecting. The link is kept in the database, and its short link and alias are never reused.
+// @Tags links
+// @Param   shortLink  path      string  true  "Short Link"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /links/{shortLink} [delete]
+func (h *Handler) DeleteLink(ctx *gin.Context) {
+	respondToUpdate(ctx, h.service.DeleteLink(ctx, ctx.Param("shortLink")))
+}
+
+// DisableLink disables a short link
+// @Summary Disable a short link
+// @Description Stop a short link from redirecting until it is enabled again. Disabled links return 410.
+// @Tags links
+// @Param   shortLink  path      string  true  "Short Link"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /links/{shortLink}/disable [post]
+func (h *Handler) DisableLink(ctx *gin.Context) {
+	respondToUpdate(ctx, h.service.SetLinkDisabled(ctx, ctx.Param("shortLink"), true))
+}
+
+// EnableLink enables a disabled short link
+// @Summary Enable a short link
+// @Description Make a disabled short link redirect again
+// @Tags links
+// @Param   shortLink  path      string  true  "Short Link"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /links/{shortLink}/enable [post]
+func (h *Handler) EnableLink(ctx *gin.Context) {
+	respondToUpdate(ctx, h.service.SetLinkDisabled(ctx, ctx.Param("shortLink"), false))
+}
+
+// respondToUpdate writes the response of a request that changes a link.
+func respondToUpdate(ctx *gin.Context, err error) {
+	switch err {
+	case nil:
+		ctx.Status(http.StatusNoContent)
+	case service.ErrShortLinkNotFound:
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
+	default:
+		ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update short link"})
+	}
+}
+
 // GetStats retrieves statistics for a short link
 // @Summary Get short link statistics
 // @Description Get the statistics of a short link, including its original URL and access count
@@ -102,8 +401,12 @@ func (h *Handler) RedirectToLongURL(ctx *gin.Context) {
 // @Produce  json
 // @Param   shortLink  path      string  true  "Short Link"
 // @Success 200 {object} map[string]interface{}
+// @Header  200 {string} ETag "Version of the link, for If-Match when editing it"
+// @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
 // @Router /stats/{shortLink} [get]
 func (h *Handler) GetStats(ctx *gin.Context) {
 	shortLink := ctx.Param("shortLink")
@@ -112,14 +415,151 @@ func (h *Handler) GetStats(ctx *gin.Context) {
 		if err == service.ErrShortLinkNotFound {
 			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
 		} else {
+			ctx.Error(err)
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link stats"})
 		}
 		return
 	}
-	res := GetStatsResponse{
-		LongURL:     stats.LongURL,
-		ShortLink:   shortLink,
-		AccessCount: stats.AccessCount,
+	ctx.Header("ETag", etag(stats.Version))
+	ctx.JSON(http.StatusOK, statsResponse(shortLink, stats))
+}
+
+// GetTimeSeries retrieves click counts of a short link over time
+// @Summary Get short link clicks over time
+// @Description Get the clicks of a short link grouped into hour, day or week buckets, with empty buckets set to zero. The range is widened to whole buckets and defaults to the last 7 days.
+// @Tags stats
+// @Accept  json
+// @Produce  json
+// @Param   shortLink  path      string  true   "Short Link"
+// @Param   from       query     string  false  "Start of the range (RFC 3339)"
+// @Param   to         query     string  false  "End of the range (RFC 3339), defaults to now"
+// @Param   interval   query     string  false  "Bucket size" Enums(hour, day, week) default(day)
+// @Param   tz         query     string  false  "IANA time zone of the buckets" default(UTC)
+// @Success 200 {object} TimeSeriesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /stats/{shortLink}/timeseries [get]
+func (h *Handler) GetTimeSeries(ctx *gin.Context) {
+	shortLink := ctx.Param("shortLink")
+
+	interval := model.Interval(ctx.DefaultQuery("interval", string(model.Day)))
+	if !interval.Valid() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected hour, day or week"})
+		return
+	}
+
+	tz := ctx.DefaultQuery("tz", "UTC")
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+		return
+	}
+
+	to := time.Now()
+	if raw := ctx.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC 3339"})
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -7)
+	if raw := ctx.Query("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC 3339"})
+			return
+		}
+	}
+
+	buckets, err := h.service.GetClickTimeSeries(ctx, shortLink, from, to, interval, loc)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidRange:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid range, from must be before to and span at most 5000 buckets"})
+		case service.ErrShortLinkNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
+		default:
+			ctx.Error(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link time series"})
+		}
+		return
+	}
+
+	res := TimeSeriesResponse{
+		ShortLink: shortLink,
+		Interval:  string(interval),
+		Timezone:  loc.String(),
+		From:      buckets[0].Start,
+		To:        interval.Next(buckets[len(buckets)-1].Start),
+		Buckets:   make([]TimeSeriesBucket, len(buckets)),
+	}
+	for i, b := range buckets {
+		res.Buckets[i] = TimeSeriesBucket{Start: b.Start, Clicks: b.Clicks}
+		res.Total += b.Clicks
 	}
 	ctx.JSON(http.StatusOK, res)
 }
+
+// linkOptions converts the optional fields of a create request, returning an
+// error message if they are invalid.
+func linkOptions(request CreateLinkRequest) (service.LinkOptions, string) {
+	opts := service.LinkOptions{Alias: request.Alias, ExpiresAt: request.ExpiresAt, Dedupe: request.Dedupe}
+	if request.TTL > 0 {
+		if request.ExpiresAt != nil {
+			return opts, "Specify either expires_at or ttl, not both"
+		}
+		expiresAt := time.Now().Add(time.Duration(request.TTL) * time.Second)
+		opts.ExpiresAt = &expiresAt
+	}
+	return opts, ""
+}
+
+// createError maps an error from creating a link to a status and error message.
+func createError(err error) (int, string) {
+	switch err {
+	case service.ErrInvalidExpiry:
+		return http.StatusBadRequest, "Expiry must be in the future"
+	case service.ErrInvalidAlias:
+		return http.StatusBadRequest, "Invalid alias: use 3-64 letters, digits, '-' or '_', including '-' or '_' if 11 characters or shorter"
+	case service.ErrAliasTaken:
+		return http.StatusConflict, "Alias already taken"
+	default:
+		return http.StatusInternalServerError, "Failed to create short link"
+	}
+}
+
+func statsResponse(shortLink string, link *model.URL) GetStatsResponse {
+	return GetStatsResponse{
+		LongURL:     link.LongURL,
+		ShortLink:   shortLink,
+		AccessCount: link.AccessCount,
+		ExpiresAt:   link.ExpiresAt,
+		Disabled:    link.Disabled,
+		UpdatedAt:   link.UpdatedAt,
+	}
+}
+
+// etag formats a link version as a strong ETag.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch returns the link version required by an If-Match header, or 0
+// if any version is fine. It reports false for values that can't match a link.
+func parseIfMatch(header string) (int64, bool) {
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
@@ -3,12 +3,16 @@ package handler_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"shortlink-go/internal/handler"
+	"shortlink-go/internal/health"
 	"shortlink-go/internal/model"
+	"shortlink-go/internal/service"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -19,14 +23,40 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) CreateShortLink(ctx context.Context, longURL string) (string, error) {
-	args := m.Called(ctx, longURL)
+func (m *MockService) CreateShortLink(ctx context.Context, longURL string, opts service.LinkOptions) (string, error) {
+	args := m.Called(ctx, longURL, opts)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockService) GetLongURL(ctx context.Context, shortLink string) (string, error) {
+func (m *MockService) CreateShortLinks(ctx context.Context, links []service.BatchLink) ([]service.BatchResult, error) {
+	args := m.Called(ctx, links)
+	if args.Get(0) != nil {
+		return args.Get(0).([]service.BatchResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) GetLongURL(ctx context.Context, shortLink string) (string, int64, error) {
 	args := m.Called(ctx, shortLink)
-	return args.String(0), args.Error(1)
+	return args.String(0), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockService) UpdateLongURL(ctx context.Context, shortLink, longURL string, version int64) (*model.URL, error) {
+	args := m.Called(ctx, shortLink, longURL, version)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.URL), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) DeleteLink(ctx context.Context, shortLink string) error {
+	args := m.Called(ctx, shortLink)
+	return args.Error(0)
+}
+
+func (m *MockService) SetLinkDisabled(ctx context.Context, shortLink string, disabled bool) error {
+	args := m.Called(ctx, shortLink, disabled)
+	return args.Error(0)
 }
 
 func (m *MockService) GetLinkStats(ctx context.Context, shortLink string) (*model.URL, error) {
@@ -37,6 +67,18 @@ func (m *MockService) GetLinkStats(ctx context.Context, shortLink string) (*mode
 	return nil, args.Error(1)
 }
 
+func (m *MockService) RecordClick(ctx context.Context, id int64, click model.Click, clientIP string) {
+	m.Called(ctx, id, click, clientIP)
+}
+
+func (m *MockService) GetClickTimeSeries(ctx context.Context, shortLink string, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	args := m.Called(ctx, shortLink, from, to, interval, loc)
+	if args.Get(0) != nil {
+		return args.Get(0).([]model.ClickBucket), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func TestHandler_CreateShortLink(t *testing.T) {
 	// Set up Gin
 	gin.SetMode(gin.TestMode)
@@ -44,11 +86,11 @@ func TestHandler_CreateShortLink(t *testing.T) {
 
 	// Create an instance of our test object
 	mockService := new(MockService)
-	handler := handler.NewHandler(mockService)
+	handler := handler.NewHandler(mockService, nil)
 
 	// Mock Service's response
 	mockShortLink := "abcd1234"
-	mockService.On("CreateShortLink", mock.Anything, "https://example.com").Return(mockShortLink, nil)
+	mockService.On("CreateShortLink", mock.Anything, "https://example.com", service.LinkOptions{}).Return(mockShortLink, nil)
 
 	// Create request and recorder
 	longURL := `{"long_url":"https://example.com"}`
@@ -75,7 +117,7 @@ func TestHandler_CreateShortLink_InvalidURL(t *testing.T) {
 	router := gin.New()
 
 	mockService := new(MockService)
-	h := handler.NewHandler(mockService)
+	h := handler.NewHandler(mockService, nil)
 
 	router.POST("/create", h.CreateShortLink)
 
@@ -94,10 +136,10 @@ func TestHandler_CreateShortLink_ServiceError(t *testing.T) {
 	router := gin.New()
 
 	mockService := new(MockService)
-	h := handler.NewHandler(mockService)
+	h := handler.NewHandler(mockService, nil)
 
 	// Service returns an error
-	mockService.On("CreateShortLink", mock.Anything, "https://example.com").Return("", errors.New("service error"))
+	mockService.On("CreateShortLink", mock.Anything, "https://example.com", service.LinkOptions{}).Return("", errors.New("service error"))
 
 	router.POST("/create", h.CreateShortLink)
 
@@ -113,9 +155,95 @@ func TestHandler_CreateShortLink_ServiceError(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestHandler_CreateShortLink_AliasTaken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	opts := service.LinkOptions{Alias: "spring-sale"}
+	mockService.On("CreateShortLink", mock.Anything, "https://example.com", opts).Return("", service.ErrAliasTaken)
+
+	router.POST("/create", h.CreateShortLink)
+
+	body := `{"long_url":"https://example.com","alias":"spring-sale"}`
+	req, _ := http.NewRequest(http.MethodPost, "/create", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_CreateShortLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	// Only the valid items reach the service.
+	mockService.On("CreateShortLinks", mock.Anything, []service.BatchLink{
+		{LongURL: "https://example.com/a"},
+		{LongURL: "https://example.com/c", Options: service.LinkOptions{Alias: "spring-sale"}},
+	}).Return([]service.BatchResult{
+		{ShortLink: "1"},
+		{Err: service.ErrAliasTaken},
+	}, nil)
+
+	router.POST("/create/batch", h.CreateShortLinks)
+
+	body := `{"links":[
+		{"long_url":"https://example.com/a"},
+		{"long_url":"not a url"},
+		{"long_url":"https://example.com/c","alias":"spring-sale"}
+	]}`
+	req, _ := http.NewRequest(http.MethodPost, "/create/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	expectedResponse := `{"created":1,"failed":2,"results":[
+		{"index":0,"status":201,"short_link":"1"},
+		{"index":1,"status":400,"error":"Bad request"},
+		{"index":2,"status":409,"error":"Alias already taken"}
+	]}`
+	assert.JSONEq(t, expectedResponse, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_CreateShortLinks_TooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	router.POST("/create/batch", h.CreateShortLinks)
+
+	links := make([]handler.CreateLinkRequest, service.MaxBatchSize+1)
+	for i := range links {
+		links[i].LongURL = "https://example.com"
+	}
+	body, _ := json.Marshal(handler.CreateBatchRequest{Links: links})
+	req, _ := http.NewRequest(http.MethodPost, "/create/batch", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateShortLinks", mock.Anything, mock.Anything)
+}
+
 func TestHandler_RedirectToLongURL(t *testing.T) {
 	mockService := new(MockService)
-	h := handler.NewHandler(mockService)
+	h := handler.NewHandler(mockService, nil)
 
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
@@ -123,10 +251,16 @@ func TestHandler_RedirectToLongURL(t *testing.T) {
 
 	shortLink := "testShortLink"
 	longURL := "http://example.com"
-	mockService.On("GetLongURL", mock.Anything, shortLink).Return(longURL, nil)
+	mockService.On("GetLongURL", mock.Anything, shortLink).Return(longURL, int64(42), nil)
+	isClick := mock.MatchedBy(func(click model.Click) bool {
+		return click.Referrer == "https://news.example.com" && click.AcceptLanguage == "en-US"
+	})
+	mockService.On("RecordClick", mock.Anything, int64(42), isClick, mock.Anything).Return()
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/"+shortLink, nil)
+	req.Header.Set("Referer", "https://news.example.com")
+	req.Header.Set("Accept-Language", "en-US")
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
@@ -134,9 +268,274 @@ func TestHandler_RedirectToLongURL(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestHandler_RegisterRoutes_Middleware(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r, handler.Middleware{
+		Auth: func(ctx *gin.Context) {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+		},
+	})
+
+	mockService.On("GetLongURL", mock.Anything, "abc").Return("http://example.com", int64(1), nil)
+	mockService.On("RecordClick", mock.Anything, int64(1), mock.Anything, mock.Anything).Return()
+
+	// Redirects and the probes skip the middleware.
+	for path, status := range map[string]int{
+		"/health":    http.StatusOK,
+		"/livez":     http.StatusOK,
+		"/readyz":    http.StatusOK,
+		"/abc":       http.StatusTemporaryRedirect,
+		"/stats/abc": http.StatusUnauthorized,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+	mockService.AssertNotCalled(t, "GetLinkStats", mock.Anything, mock.Anything)
+}
+
+func TestHandler_RegisterRoutes_RateLimits(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	limit := func(name string) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			ctx.Header("X-Limit", name)
+		}
+	}
+	h.RegisterRoutes(r, handler.Middleware{
+		RedirectLimit: limit("redirect"),
+		CreateLimit:   limit("create"),
+		APILimit:      limit("api"),
+	})
+
+	mockService.On("GetLongURL", mock.Anything, "abc").Return("http://example.com", int64(1), nil)
+	mockService.On("RecordClick", mock.Anything, int64(1), mock.Anything, mock.Anything).Return()
+	mockService.On("DeleteLink", mock.Anything, "abc").Return(nil)
+
+	for _, tc := range []struct{ method, path, limit string }{
+		{http.MethodGet, "/health", ""},
+		{http.MethodGet, "/readyz", ""},
+		{http.MethodGet, "/abc", "redirect"},
+		{http.MethodPost, "/create", "create"},
+		{http.MethodPost, "/create/batch", "create"},
+		{http.MethodDelete, "/links/abc", "api"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.limit, w.Header().Get("X-Limit"), tc.path)
+	}
+}
+
+func TestHandler_Ready(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		drain  bool
+		check  health.Check
+		status int
+		body   string
+	}{
+		"up": {
+			check:  func(ctx context.Context) error { return nil },
+			status: http.StatusOK,
+			body:   `{"status":"ready","checks":{"database":{"status":"up"}}}`,
+		},
+		"down": {
+			check:  func(ctx context.Context) error { return errors.New("connection refused") },
+			status: http.StatusServiceUnavailable,
+			body:   `{"status":"not ready","checks":{"database":{"status":"down","error":"connection refused"}}}`,
+		},
+		"shutting down": {
+			drain:  true,
+			check:  func(ctx context.Context) error { return nil },
+			status: http.StatusServiceUnavailable,
+			body:   `{"status":"shutting down","checks":{"database":{"status":"up"}}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			checker.Add("database", tc.check)
+			if tc.drain {
+				assert.NoError(t, checker.Drain(context.Background(), 0))
+			}
+			router := gin.New()
+			router.GET("/readyz", handler.NewHandler(new(MockService), checker).Ready)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			// Latency varies, so compare without it.
+			var report map[string]any
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			delete(report["checks"].(map[string]any)["database"].(map[string]any), "latency_ms")
+			got, _ := json.Marshal(report)
+			assert.JSONEq(t, tc.body, string(got))
+		})
+	}
+}
+
+func TestHandler_CreateShortLink_TTL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	hasExpiry := mock.MatchedBy(func(opts service.LinkOptions) bool {
+		return opts.ExpiresAt != nil && time.Until(*opts.ExpiresAt) > 59*time.Minute
+	})
+	mockService.On("CreateShortLink", mock.Anything, "https://example.com", hasExpiry).Return("abc", nil)
+
+	router.POST("/create", h.CreateShortLink)
+
+	body := `{"long_url":"https://example.com","ttl":3600}`
+	req, _ := http.NewRequest(http.MethodPost, "/create", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_RedirectToLongURL_Expired(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:shortLink", h.RedirectToLongURL)
+
+	mockService.On("GetLongURL", mock.Anything, "expired").Return("", int64(0), service.ErrShortLinkExpired)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/expired", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_UpdateLink(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r, handler.Middleware{})
+
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockService.On("UpdateLongURL", mock.Anything, "abc", "https://example.com/new", int64(2)).
+		Return(&model.URL{ID: 1, LongURL: "https://example.com/new", AccessCount: 4, Version: 3, UpdatedAt: &updatedAt}, nil)
+	mockService.On("UpdateLongURL", mock.Anything, "abc", "https://example.com/new", int64(1)).Return(nil, service.ErrVersionMismatch)
+
+	body := `{"long_url":"https://example.com/new"}`
+	req, _ := http.NewRequest(http.MethodPatch, "/links/abc", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"2"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.JSONEq(t, `{"long_url":"https://example.com/new","short_link":"abc","access_count":4,"disabled":false,"updated_at":"2024-03-01T12:00:00Z"}`, w.Body.String())
+
+	// Stale and malformed versions are rejected.
+	for _, ifMatch := range []string{`"1"`, `W/"2"`} {
+		req, _ = http.NewRequest(http.MethodPatch, "/links/abc", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, ifMatch)
+	}
+
+	// Invalid URLs are rejected like on create.
+	req, _ = http.NewRequest(http.MethodPatch, "/links/abc", bytes.NewBufferString(`{"long_url":"not a url"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_DeleteLink(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r, handler.Middleware{})
+
+	mockService.On("DeleteLink", mock.Anything, "abc").Return(nil)
+	mockService.On("DeleteLink", mock.Anything, "missing").Return(service.ErrShortLinkNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/links/abc", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/links/missing", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_DisableAndEnableLink(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r, handler.Middleware{})
+
+	mockService.On("SetLinkDisabled", mock.Anything, "abc", true).Return(nil)
+	mockService.On("SetLinkDisabled", mock.Anything, "abc", false).Return(nil)
+
+	for _, action := range []string{"disable", "enable"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/links/abc/"+action, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code, action)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_RedirectToLongURL_Disabled(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:shortLink", h.RedirectToLongURL)
+
+	mockService.On("GetLongURL", mock.Anything, "disabled").Return("", int64(0), service.ErrShortLinkDisabled)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/disabled", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.JSONEq(t, `{"error":"Short link disabled"}`, w.Body.String())
+	mockService.AssertNotCalled(t, "RecordClick", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestHandler_GetStats(t *testing.T) {
 	mockService := new(MockService)
-	h := handler.NewHandler(mockService)
+	h := handler.NewHandler(mockService, nil)
 
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
@@ -165,3 +564,51 @@ func TestHandler_GetStats(t *testing.T) {
 	assert.Equal(t, expectedBody.AccessCount, stats.AccessCount)
 	mockService.AssertExpectations(t)
 }
+
+func TestHandler_GetTimeSeries(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stats/:shortLink/timeseries", h.GetTimeSeries)
+
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	buckets := []model.ClickBucket{
+		{Start: time.Date(2024, 2, 29, 0, 0, 0, 0, loc), Clicks: 0},
+		{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, loc), Clicks: 3},
+		{Start: time.Date(2024, 3, 2, 0, 0, 0, 0, loc), Clicks: 2},
+	}
+	mockService.On("GetClickTimeSeries", mock.Anything, "abc", from, to, model.Day, loc).Return(buckets, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats/abc/timeseries?from=2024-03-01T00:00:00Z&to=2024-03-03T00:00:00Z&interval=day&tz=Europe/Berlin", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res handler.TimeSeriesResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int64(5), res.Total)
+	assert.Len(t, res.Buckets, 3)
+	assert.Equal(t, "Europe/Berlin", res.Timezone)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetTimeSeries_InvalidParams(t *testing.T) {
+	mockService := new(MockService)
+	h := handler.NewHandler(mockService, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stats/:shortLink/timeseries", h.GetTimeSeries)
+
+	for _, query := range []string{"interval=month", "tz=Mars/Olympus", "from=yesterday"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stats/abc/timeseries?"+query, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockService.AssertNotCalled(t, "GetClickTimeSeries")
+}
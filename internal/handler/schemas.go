@@ -1,11 +1,67 @@
 package handler
 
+import "time"
+
 type CreateLinkRequest struct {
+	// The "url" tag validates that the field is a valid URL.
+	LongURL string `json:"long_url" binding:"required,url"`
+	// Optional custom short link, e.g. "spring-sale".
+	Alias string `json:"alias,omitempty"`
+	// Optional absolute expiry time (RFC 3339). Mutually exclusive with ttl.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Optional lifetime in seconds, relative to creation. Mutually exclusive with expires_at.
+	TTL int64 `json:"ttl,omitempty" binding:"gte=0"`
+	// Optional: return the existing short link if this URL was already shortened.
+	// Defaults to the server setting. Ignored for links with an alias or expiry.
+	Dedupe *bool `json:"dedupe,omitempty"`
+}
+
+type UpdateLinkRequest struct {
+	// The "url" tag validates that the field is a valid URL.
 	LongURL string `json:"long_url" binding:"required,url"`
 }
 
+type CreateBatchRequest struct {
+	// Links to create, at most 1000.
+	Links []CreateLinkRequest `json:"links" binding:"required,min=1"`
+}
+
+// BatchLinkResult is the outcome of one link of a batch.
+type BatchLinkResult struct {
+	// Position of the link in the request.
+	Index int `json:"index"`
+	// HTTP status the link would have got from POST /create.
+	Status    int    `json:"status"`
+	ShortLink string `json:"short_link,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type CreateBatchResponse struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchLinkResult `json:"results"`
+}
+
 type GetStatsResponse struct {
-	LongURL     string `json:"long_url"`
-	ShortLink   string `json:"short_link"`
-	AccessCount int64  `json:"access_count"`
+	LongURL     string     `json:"long_url"`
+	ShortLink   string     `json:"short_link"`
+	AccessCount int64      `json:"access_count"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+type TimeSeriesBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+type TimeSeriesResponse struct {
+	ShortLink string             `json:"short_link"`
+	Interval  string             `json:"interval"`
+	Timezone  string             `json:"timezone"`
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	Total     int64              `json:"total"`
+	Buckets   []TimeSeriesBucket `json:"buckets"`
 }
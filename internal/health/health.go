@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a report and of its checks.
+const (
+	StatusReady        = "ready"
+	StatusDegraded     = "degraded"
+	StatusNotReady     = "not ready"
+	StatusShuttingDown = "shutting down"
+
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check returns an error if a dependency can't be reached.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// Optional dependencies don't make the service unready when down.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Report is the readiness of the service and the results of its checks.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready reports whether the service should get traffic.
+func (r Report) Ready() bool {
+	return r.Status == StatusReady || r.Status == StatusDegraded
+}
+
+// Checker checks the dependencies of the service.
+type Checker struct {
+	timeout      time.Duration
+	names        []string
+	checks       []Check
+	optional     []bool
+	shuttingDown atomic.Bool
+}
+
+// NewChecker returns a checker giving each check timeout to complete.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add adds a check of the dependency name. Checks must be added before Ready
+// is first called.
+func (c *Checker) Add(name string, check Check) {
+	c.add(name, check, false)
+}
+
+// AddOptional adds a check of the dependency name that the service can run
+// without. While it fails, the service is degraded but still ready.
+func (c *Checker) AddOptional(name string, check Check) {
+	c.add(name, check, true)
+}
+
+func (c *Checker) add(name string, check Check, optional bool) {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+	c.optional = append(c.optional, optional)
+}
+
+// Ready runs the checks concurrently. The service is ready once the required
+// ones pass, unless it is shutting down.
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusReady, Checks: make(map[string]Result, len(c.checks))}
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+			results[i].Optional = c.optional[i]
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		report.Checks[c.names[i]] = result
+		switch {
+		case result.Status == StatusUp:
+		case !result.Optional:
+			report.Status = StatusNotReady
+		case report.Status == StatusReady:
+			report.Status = StatusDegraded
+		}
+	}
+	// Checks still run, so their results show why a shutdown is slow.
+	if c.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Drain makes the service not ready from now on, so load balancers stop
+// sending it new requests, and waits delay for them to notice or until ctx is
+// done.
+func (c *Checker) Drain(ctx context.Context, delay time.Duration) error {
+	c.shuttingDown.Store(true)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,110 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/health"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func up(ctx context.Context) error { return nil }
+
+func TestChecker_Ready(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.Add("database", up)
+	c.Add("redis", up)
+
+	report := c.Ready(context.Background())
+
+	assert.True(t, report.Ready())
+	assert.Equal(t, health.StatusReady, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+	assert.Empty(t, report.Checks["redis"].Error)
+}
+
+func TestChecker_NoChecks(t *testing.T) {
+	report := health.NewChecker(time.Second).Ready(context.Background())
+
+	assert.True(t, report.Ready())
+	assert.Empty(t, report.Checks)
+}
+
+func TestChecker_DependencyDown(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.Add("database", up)
+	c.Add("redis", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	report := c.Ready(context.Background())
+
+	assert.False(t, report.Ready())
+	assert.Equal(t, health.StatusNotReady, report.Status)
+	assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+	assert.Equal(t, health.Result{Status: health.StatusDown, LatencyMS: report.Checks["redis"].LatencyMS, Error: "connection refused"}, report.Checks["redis"])
+}
+
+func TestChecker_OptionalDependencyDown(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.Add("database", up)
+	c.AddOptional("redis", func(ctx context.Context) error {
+		return errors.New("redis circuit breaker is open")
+	})
+
+	report := c.Ready(context.Background())
+
+	assert.True(t, report.Ready())
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusDown, report.Checks["redis"].Status)
+	assert.True(t, report.Checks["redis"].Optional)
+	assert.False(t, report.Checks["database"].Optional)
+
+	c.Add("clock", func(ctx context.Context) error { return errors.New("skewed") })
+	assert.Equal(t, health.StatusNotReady, c.Ready(context.Background()).Status)
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	c := health.NewChecker(20 * time.Millisecond)
+	c.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := c.Ready(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Ready())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["database"].Error)
+	assert.GreaterOrEqual(t, report.Checks["database"].LatencyMS, float64(20))
+}
+
+func TestChecker_Drain(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.Add("database", up)
+
+	require.NoError(t, c.Drain(context.Background(), 0))
+
+	report := c.Ready(context.Background())
+	assert.False(t, report.Ready())
+	assert.Equal(t, health.StatusShuttingDown, report.Status)
+	assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+}
+
+func TestChecker_DrainBoundedByContext(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Drain(ctx, time.Minute)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, c.Ready(context.Background()).Ready())
+}
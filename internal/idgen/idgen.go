@@ -0,0 +1,99 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	sequenceMask = 1<<sequenceBits - 1
+
+	// MaxNode is the largest node ID of a Snowflake generator.
+	MaxNode = 1<<nodeBits - 1
+)
+
+// Epoch is the time Snowflake timestamps count from. Moving it would make
+// generators repeat IDs.
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates IDs from the time in milliseconds since Epoch, the node
+// ID and a per-millisecond sequence, so instances with different node IDs
+// never generate the same ID without coordinating. Up to 4096 IDs are
+// generated per millisecond; past that, and when the clock goes backwards, the
+// generator borrows from the next millisecond rather than waiting.
+type Snowflake struct {
+	node int64
+
+	mu       sync.Mutex
+	lastTime int64
+	sequence int64
+}
+
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("node ID %d out of range [0, %d]", node, MaxNode)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+func (g *Snowflake) NextID(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	t := max(time.Since(Epoch).Milliseconds(), g.lastTime)
+	if t == g.lastTime {
+		g.sequence = (g.sequence + 1) & sequenceMask
+		if g.sequence == 0 {
+			t++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = t
+	return t<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence, nil
+}
+
+// BlockStore reserves ranges of IDs, e.g. repository.PGURLRepository.
+type BlockStore interface {
+	// ReserveIDs reserves n consecutive IDs and returns the first one.
+	ReserveIDs(ctx context.Context, n int64) (int64, error)
+}
+
+// Block hands out IDs from blocks reserved from a BlockStore, so only one in
+// every size IDs costs a round trip. IDs left in the block when the instance
+// stops are never used, and IDs of different instances interleave in time.
+type Block struct {
+	store BlockStore
+	size  int64
+
+	mu   sync.Mutex
+	next int64
+	end  int64 // Exclusive.
+}
+
+func NewBlock(store BlockStore, size int64) *Block {
+	return &Block{
+		store: store,
+		size:  size,
+	}
+}
+
+func (g *Block) NextID(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next == g.end {
+		first, err := g.store.ReserveIDs(ctx, g.size)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reserve IDs: %w", err)
+		}
+		g.next, g.end = first, first+g.size
+	}
+	id := g.next
+	g.next++
+	return id, nil
+}
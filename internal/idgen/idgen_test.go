@@ -0,0 +1,86 @@
+package idgen_test
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/idgen"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowflake_Unique(t *testing.T) {
+	ctx := context.Background()
+	a, err := idgen.NewSnowflake(1)
+	assert.NoError(t, err)
+	b, err := idgen.NewSnowflake(2)
+	assert.NoError(t, err)
+
+	// More IDs than fit in a millisecond, from two nodes at once.
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for _, g := range []*idgen.Snowflake{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for range 10000 {
+				id, err := g.NextID(ctx)
+				assert.NoError(t, err)
+				assert.Greater(t, id, last)
+				last = id
+				mu.Lock()
+				assert.False(t, seen[id], "duplicate ID %d", id)
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewSnowflake_InvalidNode(t *testing.T) {
+	_, err := idgen.NewSnowflake(idgen.MaxNode + 1)
+	assert.Error(t, err)
+	_, err = idgen.NewSnowflake(-1)
+	assert.Error(t, err)
+}
+
+type blockStore struct {
+	next  int64
+	calls int
+	err   error
+}
+
+func (s *blockStore) ReserveIDs(ctx context.Context, n int64) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.calls++
+	first := s.next
+	s.next += n
+	return first, nil
+}
+
+func TestBlock(t *testing.T) {
+	ctx := context.Background()
+	store := &blockStore{next: 100}
+	g := idgen.NewBlock(store, 3)
+
+	for want := int64(100); want < 107; want++ {
+		id, err := g.NextID(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+	assert.Equal(t, 3, store.calls)
+
+	store.err = errors.New("database down")
+	for range 2 {
+		_, err := g.NextID(ctx)
+		assert.NoError(t, err)
+	}
+	_, err := g.NextID(ctx)
+	assert.ErrorIs(t, err, store.err)
+}
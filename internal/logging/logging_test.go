@@ -0,0 +1,165 @@
+package logging_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"shortlink-go/internal/logging"
+	"shortlink-go/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs makes the default logger write JSON to the returned buffer at
+// debug level for the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "debug")
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var out []map[string]any
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		out = append(out, record)
+	}
+	return out
+}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(logging.Middleware(), logging.Recovery())
+	r.GET("/:shortLink", func(ctx *gin.Context) {
+		logging.FromContext(ctx.Request.Context()).Warn("Looking up", "short_link", ctx.Param("shortLink"))
+		ctx.Status(http.StatusFound)
+	})
+	r.GET("/panic/now", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	return r
+}
+
+func TestNew_InvalidLevel(t *testing.T) {
+	_, err := logging.New(&bytes.Buffer{}, "loud")
+	assert.Error(t, err)
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "warn")
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	got := records(t, &buf)
+	require.Len(t, got, 1)
+	assert.Equal(t, "kept", got[0]["msg"])
+}
+
+func TestMiddleware_PropagatesRequestID(t *testing.T) {
+	buf := captureLogs(t)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+
+	newRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, "req-42", w.Header().Get(logging.RequestIDHeader))
+	got := records(t, buf)
+	require.Len(t, got, 2)
+	assert.Equal(t, "Looking up", got[0]["msg"])
+	assert.Equal(t, "req-42", got[0]["request_id"])
+	assert.Equal(t, "Request", got[1]["msg"])
+	assert.Equal(t, "req-42", got[1]["request_id"])
+	assert.Equal(t, "/:shortLink", got[1]["route"])
+	assert.Equal(t, float64(http.StatusFound), got[1]["status"])
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	for name, header := range map[string]string{
+		"missing":  "",
+		"spaces":   "a b",
+		"too long": strings.Repeat("a", 129),
+	} {
+		t.Run(name, func(t *testing.T) {
+			captureLogs(t)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			if header != "" {
+				req.Header.Set(logging.RequestIDHeader, header)
+			}
+
+			newRouter().ServeHTTP(w, req)
+
+			id := w.Header().Get(logging.RequestIDHeader)
+			assert.Len(t, id, 32)
+			assert.NotEqual(t, header, id)
+		})
+	}
+}
+
+func TestRecovery_LogsPanic(t *testing.T) {
+	buf := captureLogs(t)
+	w := httptest.NewRecorder()
+
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic/now", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	got := records(t, buf)
+	require.Len(t, got, 2)
+	assert.Equal(t, "boom", got[0]["panic"])
+	assert.Equal(t, got[0]["request_id"], got[1]["request_id"])
+	assert.Equal(t, "ERROR", got[1]["level"])
+}
+
+func TestFromContext_Default(t *testing.T) {
+	assert.Same(t, slog.Default(), logging.FromContext(context.Background()))
+}
+
+type failingURLRepository struct {
+	repository.URLRepository
+	err error
+}
+
+func (r failingURLRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
+	return 0, r.err
+}
+
+func TestLogURLRepository(t *testing.T) {
+	buf := captureLogs(t)
+	ctx := logging.WithLogger(context.Background(), slog.Default().With("request_id", "req-42"))
+
+	repo := logging.LogURLRepository(failingURLRepository{err: repository.ErrNotFound})
+	_, err := repo.GetIDByAlias(ctx, "docs")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	repo = logging.LogURLRepository(failingURLRepository{err: errors.New("connection refused")})
+	_, err = repo.GetIDByAlias(ctx, "docs")
+	assert.Error(t, err)
+
+	got := records(t, buf)
+	require.Len(t, got, 2)
+	assert.Equal(t, "DEBUG", got[0]["level"])
+	assert.Equal(t, "ERROR", got[1]["level"])
+	assert.Equal(t, "GetIDByAlias", got[1]["method"])
+	assert.Equal(t, "connection refused", got[1]["error"])
+	assert.Equal(t, "req-42", got[1]["request_id"])
+}
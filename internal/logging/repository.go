@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"time"
+)
+
+// LogURLRepository returns a URLRepository logging every call of next at
+// debug level, and calls failing for other reasons than the state of the
+// link at error level, with the logger of the request.
+func LogURLRepository(next repository.URLRepository) repository.URLRepository {
+	return &urlRepository{next: next}
+}
+
+type urlRepository struct {
+	next repository.URLRepository
+}
+
+// log logs a call of method started at start, which returned *err.
+func (r *urlRepository) log(ctx context.Context, method string, start time.Time, err *error) {
+	logger := FromContext(ctx)
+	if *err != nil && !expected(*err) {
+		logger.ErrorContext(ctx, "Repository call failed", "method", method, "duration", time.Since(start), "error", *err)
+		return
+	}
+	logger.DebugContext(ctx, "Repository call", "method", method, "duration", time.Since(start))
+}
+
+// expected reports whether err is one of the outcomes callers handle, rather
+// than a failure of the storage.
+func expected(err error) bool {
+	return errors.Is(err, repository.ErrNotFound) ||
+		errors.Is(err, repository.ErrAliasTaken) ||
+		errors.Is(err, repository.ErrIDTaken) ||
+		errors.Is(err, repository.ErrVersionMismatch)
+}
+
+func (r *urlRepository) CreateShortLink(ctx context.Context, url *model.URL) (id int64, err error) {
+	defer r.log(ctx, "CreateShortLink", time.Now(), &err)
+	return r.next.CreateShortLink(ctx, url)
+}
+
+func (r *urlRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) (ids []int64, err error) {
+	defer r.log(ctx, "CreateShortLinks", time.Now(), &err)
+	return r.next.CreateShortLinks(ctx, urls)
+}
+
+func (r *urlRepository) GetLongURL(ctx context.Context, id int64) (url *model.URL, err error) {
+	defer r.log(ctx, "GetLongURL", time.Now(), &err)
+	return r.next.GetLongURL(ctx, id)
+}
+
+func (r *urlRepository) GetIDByAlias(ctx context.Context, alias string) (id int64, err error) {
+	defer r.log(ctx, "GetIDByAlias", time.Now(), &err)
+	return r.next.GetIDByAlias(ctx, alias)
+}
+
+func (r *urlRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (id int64, err error) {
+	defer r.log(ctx, "GetIDByLongURLHash", time.Now(), &err)
+	return r.next.GetIDByLongURLHash(ctx, hash, ownerKeyID)
+}
+
+func (r *urlRepository) GetURLStats(ctx context.Context, id int64) (url *model.URL, err error) {
+	defer r.log(ctx, "GetURLStats", time.Now(), &err)
+	return r.next.GetURLStats(ctx, id)
+}
+
+func (r *urlRepository) UpdateLongURL(ctx context.Context, url *model.URL) (err error) {
+	defer r.log(ctx, "UpdateLongURL", time.Now(), &err)
+	return r.next.UpdateLongURL(ctx, url)
+}
+
+func (r *urlRepository) DeleteShortLink(ctx context.Context, id int64) (err error) {
+	defer r.log(ctx, "DeleteShortLink", time.Now(), &err)
+	return r.next.DeleteShortLink(ctx, id)
+}
+
+func (r *urlRepository) SetDisabled(ctx context.Context, id int64, disabled bool) (err error) {
+	defer r.log(ctx, "SetDisabled", time.Now(), &err)
+	return r.next.SetDisabled(ctx, id, disabled)
+}
+
+func (r *urlRepository) IncrementAccessCount(ctx context.Context, id int64) (err error) {
+	defer r.log(ctx, "IncrementAccessCount", time.Now(), &err)
+	return r.next.IncrementAccessCount(ctx, id)
+}
+
+func (r *urlRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) (err error) {
+	defer r.log(ctx, "IncrementAccessCounts", time.Now(), &err)
+	return r.next.IncrementAccessCounts(ctx, counts)
+}
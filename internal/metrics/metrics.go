@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"math"
+	"net/http"
+	"shortlink-go/internal/cache"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortlink"
+
+// Registry holds the metrics served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// CacheLookups counts lookups of long URLs in the cache by result: "hit",
+	// "miss", "error" or "skipped" while Redis is down.
+	CacheLookups = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Long URL lookups in the cache by result.",
+	}, []string{"result"})
+
+	dbDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database latency by URL repository method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records the count and latency of requests. Requests that match no
+// route are recorded under "unmatched", so scanners can't blow up the number
+// of series.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := prometheus.Labels{
+			"method": ctx.Request.Method,
+			"route":  route,
+			"status": strconv.Itoa(ctx.Writer.Status()),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB exposes the connection pool stats of db.
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Backlogger reports how many increments a background writer accepted but
+// hasn't persisted yet, e.g. counter.Aggregator.
+type Backlogger interface {
+	Backlog(ctx context.Context) (int64, error)
+}
+
+// Dropper reports how many increments a background writer discarded, e.g.
+// counter.Aggregator.
+type Dropper interface {
+	Dropped() int64
+}
+
+// RegisterAccessCounter exposes the pending and, if c reports them, dropped
+// access count increments of c.
+func RegisterAccessCounter(c Backlogger) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "access_counts_pending",
+		Help:      "Access count increments not yet written to the database.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n, err := c.Backlog(ctx)
+		if err != nil {
+			slog.Warn("Failed to read pending access counts", "error", err)
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+	if d, ok := c.(Dropper); ok {
+		registerDropped("access_counts_dropped_total", "Access count increments discarded because the buffer was full.", d)
+	}
+}
+
+// Queuer reports how many items wait in a background queue, e.g. analytics.Recorder.
+type Queuer interface {
+	Queued() int
+}
+
+// ClickRecorder is a click queue like analytics.Recorder.
+type ClickRecorder interface {
+	Queuer
+	Dropper
+}
+
+// RegisterClickRecorder exposes the queued and dropped click events of r.
+func RegisterClickRecorder(r ClickRecorder) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clicks_queued",
+		Help:      "Click events waiting to be written to the database.",
+	}, func() float64 {
+		return float64(r.Queued())
+	}))
+	registerDropped("clicks_dropped_total", "Click events discarded because the queue was full.", r)
+}
+
+// LocalCache reports the counters of an in-process cache, e.g. cache.TieredClient.
+type LocalCache interface {
+	Stats() cache.LRUStats
+}
+
+// RegisterLocalCache exposes the hits, misses and size of c.
+func RegisterLocalCache(c LocalCache) {
+	Registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "local_cache_hits_total",
+			Help:      "Lookups served from the in-process cache.",
+		}, func() float64 {
+			return float64(c.Stats().Hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "local_cache_misses_total",
+			Help:      "Lookups missing or expired in the in-process cache.",
+		}, func() float64 {
+			return float64(c.Stats().Misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "local_cache_entries",
+			Help:      "Entries held by the in-process cache.",
+		}, func() float64 {
+			return float64(c.Stats().Size)
+		}),
+	)
+}
+
+// RedisBreaker reports whether Redis is being skipped and how many commands
+// were, e.g. cache.Breaker.
+type RedisBreaker interface {
+	Open() bool
+	Skipped() int64
+}
+
+// RegisterRedisBreaker exposes whether b is open and the commands it skipped.
+func RegisterRedisBreaker(b RedisBreaker) {
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "redis_circuit_open",
+			Help:      "Whether Redis is skipped because it keeps failing (1) or in use (0).",
+		}, func() float64 {
+			if b.Open() {
+				return 1
+			}
+			return 0
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redis_commands_skipped_total",
+			Help:      "Redis commands not sent because the circuit breaker was open.",
+		}, func() float64 {
+			return float64(b.Skipped())
+		}),
+	)
+}
+
+func registerDropped(name, help string, d Dropper) {
+	Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		return float64(d.Dropped())
+	}))
+}
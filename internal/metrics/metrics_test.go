@@ -0,0 +1,97 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shortlink-go/internal/cache"
+	"shortlink-go/internal/metrics"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMiddleware_LabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(metrics.Middleware())
+	r.GET("/:shortLink", func(ctx *gin.Context) {
+		ctx.Status(http.StatusFound)
+	})
+
+	for _, path := range []string{"/abc", "/def", "/a/b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t)
+	assert.Contains(t, body, `shortlink_http_requests_total{method="GET",route="/:shortLink",status="302"} 2`)
+	assert.Contains(t, body, `shortlink_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `shortlink_http_request_duration_seconds_count{method="GET",route="/:shortLink",status="302"} 2`)
+}
+
+func TestInstrumentURLRepository(t *testing.T) {
+	repo := metrics.InstrumentURLRepository(repository.NewMemURLRepository())
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "https://example.com"})
+	require.NoError(t, err)
+	url, err := repo.GetLongURL(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", url.LongURL)
+	_, err = repo.GetLongURL(ctx, id+1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	body := scrape(t)
+	assert.Contains(t, body, `shortlink_db_query_duration_seconds_count{method="CreateShortLink"} 1`)
+	assert.Contains(t, body, `shortlink_db_query_duration_seconds_count{method="GetLongURL"} 2`)
+}
+
+type backlog int64
+
+func (b backlog) Backlog(ctx context.Context) (int64, error) { return int64(b), nil }
+func (b backlog) Dropped() int64                             { return 3 }
+
+func TestRegisterAccessCounter(t *testing.T) {
+	metrics.RegisterAccessCounter(backlog(7))
+
+	body := scrape(t)
+	assert.Contains(t, body, "shortlink_access_counts_pending 7")
+	assert.Contains(t, body, "shortlink_access_counts_dropped_total 3")
+}
+
+type breaker bool
+
+func (b breaker) Open() bool     { return bool(b) }
+func (b breaker) Skipped() int64 { return 4 }
+
+func TestRegisterRedisBreaker(t *testing.T) {
+	metrics.RegisterRedisBreaker(breaker(true))
+
+	body := scrape(t)
+	assert.Contains(t, body, "shortlink_redis_circuit_open 1")
+	assert.Contains(t, body, "shortlink_redis_commands_skipped_total 4")
+}
+
+type localCache cache.LRUStats
+
+func (c localCache) Stats() cache.LRUStats { return cache.LRUStats(c) }
+
+func TestRegisterLocalCache(t *testing.T) {
+	metrics.RegisterLocalCache(localCache{Hits: 5, Misses: 2, Size: 3})
+
+	body := scrape(t)
+	assert.Contains(t, body, "shortlink_local_cache_hits_total 5")
+	assert.Contains(t, body, "shortlink_local_cache_misses_total 2")
+	assert.Contains(t, body, "shortlink_local_cache_entries 3")
+}
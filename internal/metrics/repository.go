@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"time"
+)
+
+// InstrumentURLRepository returns a URLRepository recording the latency of
+// every method of next.
+func InstrumentURLRepository(next repository.URLRepository) repository.URLRepository {
+	return &urlRepository{next: next}
+}
+
+type urlRepository struct {
+	next repository.URLRepository
+}
+
+func observe(method string, start time.Time) {
+	dbDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (r *urlRepository) CreateShortLink(ctx context.Context, url *model.URL) (int64, error) {
+	defer observe("CreateShortLink", time.Now())
+	return r.next.CreateShortLink(ctx, url)
+}
+
+func (r *urlRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error) {
+	defer observe("CreateShortLinks", time.Now())
+	return r.next.CreateShortLinks(ctx, urls)
+}
+
+func (r *urlRepository) GetLongURL(ctx context.Context, id int64) (*model.URL, error) {
+	defer observe("GetLongURL", time.Now())
+	return r.next.GetLongURL(ctx, id)
+}
+
+func (r *urlRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
+	defer observe("GetIDByAlias", time.Now())
+	return r.next.GetIDByAlias(ctx, alias)
+}
+
+func (r *urlRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error) {
+	defer observe("GetIDByLongURLHash", time.Now())
+	return r.next.GetIDByLongURLHash(ctx, hash, ownerKeyID)
+}
+
+func (r *urlRepository) GetURLStats(ctx context.Context, id int64) (*model.URL, error) {
+	defer observe("GetURLStats", time.Now())
+	return r.next.GetURLStats(ctx, id)
+}
+
+func (r *urlRepository) UpdateLongURL(ctx context.Context, url *model.URL) error {
+	defer observe("UpdateLongURL", time.Now())
+	return r.next.UpdateLongURL(ctx, url)
+}
+
+func (r *urlRepository) DeleteShortLink(ctx context.Context, id int64) error {
+	defer observe("DeleteShortLink", time.Now())
+	return r.next.DeleteShortLink(ctx, id)
+}
+
+func (r *urlRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	defer observe("SetDisabled", time.Now())
+	return r.next.SetDisabled(ctx, id, disabled)
+}
+
+func (r *urlRepository) IncrementAccessCount(ctx context.Context, id int64) error {
+	defer observe("IncrementAccessCount", time.Now())
+	return r.next.IncrementAccessCount(ctx, id)
+}
+
+func (r *urlRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	defer observe("IncrementAccessCounts", time.Now())
+	return r.next.IncrementAccessCounts(ctx, counts)
+}
@@ -1,8 +1,87 @@
 package model
 
+import "time"
+
 // URL struct represents the URL table structure from your database in Go.
 type URL struct {
 	ID          int64
 	LongURL     string
+	Alias       string // Empty when the link only has a generated short link.
 	AccessCount int64
+	ExpiresAt   *time.Time // Nil when the link never expires.
+	LongURLHash string     // SHA-256 of the normalized long URL, used to deduplicate links.
+	Disabled    bool       // Disabled links are kept but don't redirect.
+	Version     int64      // Starts at 1 and is bumped on every edit.
+	UpdatedAt   *time.Time // Nil until the link is first edited.
+	OwnerKeyID  int64      // ID of the API key that created the link, 0 if none did.
+}
+
+// APIKey is a credential of an API client. Only a hash of the key itself is stored.
+type APIKey struct {
+	ID        int64
+	Name      string
+	KeyHash   string
+	CreatedAt time.Time
+	RevokedAt *time.Time // Nil while the key is valid.
+}
+
+// Expired reports whether the link has passed its expiry time.
+func (u *URL) Expired() bool {
+	return u.ExpiresAt != nil && !time.Now().Before(*u.ExpiresAt)
+}
+
+// Click represents one redirect of a short link, from the clicks table.
+type Click struct {
+	URLID          int64
+	ClickedAt      time.Time
+	Referrer       string
+	UserAgent      string
+	IPHash         string // Keyed hash of the client IP, so visitors can be told apart but not identified.
+	AcceptLanguage string
+}
+
+// Interval is the width of a time series bucket.
+type Interval string
+
+const (
+	Hour Interval = "hour"
+	Day  Interval = "day"
+	Week Interval = "week" // Weeks start on Monday.
+)
+
+// Valid reports whether i is a supported interval.
+func (i Interval) Valid() bool {
+	return i == Hour || i == Day || i == Week
+}
+
+// Truncate returns the start of the bucket containing t, in loc.
+func (i Interval) Truncate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch i {
+	case Hour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case Week:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// Next returns the start of the bucket following the one starting at start.
+func (i Interval) Next(start time.Time) time.Time {
+	switch i {
+	case Hour:
+		return start.Add(time.Hour)
+	case Week:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// ClickBucket is the number of clicks in the interval starting at Start.
+type ClickBucket struct {
+	Start  time.Time
+	Clicks int64
 }
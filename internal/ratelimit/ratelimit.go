@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"shortlink-go/config"
+	"shortlink-go/internal/auth"
+	"shortlink-go/internal/cache"
+	"shortlink-go/internal/logging"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedisKeyPrefix starts the keys of the token buckets. The ":" after it keeps
+// them apart from the "shortlink:<code>" cache keys, as short links and aliases
+// never contain one.
+const RedisKeyPrefix = "shortlink:ratelimit:"
+
+// tokenBucketScript takes cost tokens from the bucket in KEYS[1], which holds
+// up to ARGV[1] tokens and refills completely in ARGV[2] milliseconds. It uses
+// the Redis clock, so instances with skewed clocks share buckets correctly.
+// It returns whether the request is allowed, the tokens left, and the
+// milliseconds until the bucket is full and until the request would be allowed.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * capacity / period)
+
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) * period / capacity)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) * period / capacity), retry}
+`
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the client has its full limit again.
+	Reset time.Duration
+	// RetryAfter is how long until the request would be allowed, 0 if it is.
+	RetryAfter time.Duration
+}
+
+// Limiter is a token bucket rate limiter kept in Redis, so the limit holds
+// across instances. Each client can burst up to the limit, and its tokens
+// refill evenly over the period.
+type Limiter struct {
+	client cache.RedisClient
+	name   string
+	rate   config.Rate
+}
+
+// NewLimiter returns a limiter for rate. The name separates the buckets of
+// limiters for different routes.
+func NewLimiter(client cache.RedisClient, name string, rate config.Rate) *Limiter {
+	return &Limiter{
+		client: client,
+		name:   name,
+		rate:   rate,
+	}
+}
+
+// Allow takes one token from the bucket of the client.
+func (l *Limiter) Allow(ctx context.Context, client string) (Result, error) {
+	res, err := l.client.Eval(ctx, tokenBucketScript, []string{RedisKeyPrefix + l.name + ":" + client},
+		l.rate.Limit, l.rate.Period.Milliseconds(), 1).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(res) != 4 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      l.rate.Limit,
+		Remaining:  int(res[1]),
+		Reset:      time.Duration(res[2]) * time.Millisecond,
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests and
+// sets the RateLimit-* headers on every response. Clients are identified by
+// their API key, so it must run after authentication, or by IP without one.
+// Requests are let through if Redis fails, so an outage doesn't take the
+// routes down with it.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", l.rate.Limit, int64(math.Ceil(l.rate.Period.Seconds())))
+	return func(ctx *gin.Context) {
+		res, err := l.Allow(ctx, clientID(ctx))
+		if err != nil {
+			// An open circuit breaker has already been logged.
+			if !errors.Is(err, cache.ErrCircuitOpen) {
+				logging.FromContext(ctx.Request.Context()).Warn("Failed to check rate limit, letting the request through", "limit", l.name, "error", err)
+			}
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Policy", policy)
+		ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		ctx.Header("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			ctx.Header("Retry-After", seconds(res.RetryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// clientID returns the API key ID of the request, or the client IP without one.
+func clientID(ctx *gin.Context) string {
+	if key, ok := auth.FromContext(ctx.Request.Context()); ok {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// seconds formats d as whole seconds, rounded up so clients don't retry too early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
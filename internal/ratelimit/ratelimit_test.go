@@ -0,0 +1,109 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shortlink-go/config"
+	"shortlink-go/internal/auth"
+	"shortlink-go/internal/cache"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	limiter := ratelimit.NewLimiter(client, "create", config.Rate{Limit: 2, Period: time.Minute})
+	ctx := context.Background()
+
+	res, err := limiter.Allow(ctx, "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+	assert.InDelta(t, 30*time.Second, res.Reset, float64(time.Second))
+
+	res, err = limiter.Allow(ctx, "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = limiter.Allow(ctx, "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, 30*time.Second, res.RetryAfter, float64(time.Second))
+
+	// Other clients have their own bucket.
+	res, err = limiter.Allow(ctx, "ip:5.6.7.8")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// Tokens refill over the period.
+	server.SetTime(time.Now().Add(31 * time.Second))
+	res, err = limiter.Allow(ctx, "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	limiter := ratelimit.NewLimiter(client, "create", config.Rate{Limit: 1, Period: 10 * time.Second})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", limiter.Middleware(), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	get := func(key *model.APIKey) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if key != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), key))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=10", w.Header().Get("RateLimit-Policy"))
+
+	w = get(nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"Rate limit exceeded"}`, w.Body.String())
+
+	// Requests with an API key are limited per key rather than per IP.
+	assert.Equal(t, http.StatusOK, get(&model.APIKey{ID: 1}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(&model.APIKey{ID: 1}).Code)
+}
+
+func TestLimiter_Middleware_RedisDown(t *testing.T) {
+	limiter := ratelimit.NewLimiter(cache.NopClient{}, "create", config.Rate{Limit: 1, Period: time.Second})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", limiter.Middleware(), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	for range 3 {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
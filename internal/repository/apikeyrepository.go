@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"shortlink-go/internal/model"
+)
+
+var ErrKeyNotFound = errors.New("no API key found")
+
+type APIKeyRepository interface {
+	// CreateAPIKey stores a new key and returns its ID.
+	CreateAPIKey(ctx context.Context, key *model.APIKey) (int64, error)
+	// GetAPIKeyByHash returns the unrevoked key with the given hash.
+	GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	// ListAPIKeys returns all keys, including revoked ones, sorted by ID.
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	// RevokeAPIKey invalidates a key. Links created with it keep it as their owner.
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
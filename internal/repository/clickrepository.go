@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"sort"
+	"time"
+)
+
+type ClickRepository interface {
+	// RecordClicks stores a batch of click events.
+	RecordClicks(ctx context.Context, clicks []model.Click) error
+	// CountClicks returns the clicks of a URL in [from, to) grouped into interval
+	// buckets in loc, sorted by start. Buckets without clicks are omitted.
+	CountClicks(ctx context.Context, urlID int64, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error)
+}
+
+// bucketClicks groups click times for repositories that can't bucket by time zone themselves.
+func bucketClicks(times []time.Time, interval model.Interval, loc *time.Location) []model.ClickBucket {
+	counts := make(map[time.Time]int64)
+	for _, t := range times {
+		counts[interval.Truncate(t, loc)]++
+	}
+
+	buckets := make([]model.ClickBucket, 0, len(counts))
+	for start, clicks := range counts {
+		buckets = append(buckets, model.ClickBucket{Start: start, Clicks: clicks})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
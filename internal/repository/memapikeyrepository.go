@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"sync"
+	"time"
+)
+
+// MemAPIKeyRepository is a thread-safe in-memory APIKeyRepository for tests.
+type MemAPIKeyRepository struct {
+	mu   sync.RWMutex
+	keys []model.APIKey // Sorted by ID, which is the position plus 1.
+}
+
+func NewMemAPIKeyRepository() *MemAPIKeyRepository {
+	return &MemAPIKeyRepository{}
+}
+
+func (r *MemAPIKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := model.APIKey{
+		ID:        int64(len(r.keys)) + 1,
+		Name:      key.Name,
+		KeyHash:   key.KeyHash,
+		CreatedAt: time.Now(),
+	}
+	r.keys = append(r.keys, stored)
+	return stored.ID, nil
+}
+
+func (r *MemAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == hash && key.RevokedAt == nil {
+			return &key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (r *MemAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]model.APIKey, len(r.keys))
+	copy(keys, r.keys)
+	return keys, nil
+}
+
+func (r *MemAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id < 1 || id > int64(len(r.keys)) || r.keys[id-1].RevokedAt != nil {
+		return ErrKeyNotFound
+	}
+	now := time.Now()
+	r.keys[id-1].RevokedAt = &now
+	return nil
+}
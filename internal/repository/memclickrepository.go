@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"sync"
+	"time"
+)
+
+// MemClickRepository is a thread-safe in-memory ClickRepository, the click
+// counterpart of MemURLRepository.
+type MemClickRepository struct {
+	mu     sync.RWMutex
+	clicks []model.Click
+}
+
+func NewMemClickRepository() *MemClickRepository {
+	return &MemClickRepository{}
+}
+
+func (r *MemClickRepository) RecordClicks(ctx context.Context, clicks []model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clicks = append(r.clicks, clicks...)
+	return nil
+}
+
+func (r *MemClickRepository) CountClicks(ctx context.Context, urlID int64, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var times []time.Time
+	for _, c := range r.clicks {
+		if c.URLID == urlID && !c.ClickedAt.Before(from) && c.ClickedAt.Before(to) {
+			times = append(times, c.ClickedAt)
+		}
+	}
+	return bucketClicks(times, interval, loc), nil
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemURLRepository is a thread-safe in-memory URLRepository for local development
+// and tests. It mirrors PGURLRepository: IDs are sequential starting at 1, and
+// missing links and taken IDs and aliases return ErrNotFound, ErrIDTaken and
+// ErrAliasTaken. Deleted links are removed, but their IDs and aliases stay
+// taken, as with soft deletes.
+type MemURLRepository struct {
+	mu      sync.RWMutex
+	urls    map[int64]*model.URL
+	deleted map[int64]bool
+	aliases map[string]int64
+	hashes  map[string][]int64 // Plain links per LongURLHash, oldest first.
+	lastID  int64
+}
+
+func NewMemURLRepository() *MemURLRepository {
+	return &MemURLRepository{
+		urls:    make(map[int64]*model.URL),
+		deleted: make(map[int64]bool),
+		aliases: make(map[string]int64),
+		hashes:  make(map[string][]int64),
+	}
+}
+
+func (r *MemURLRepository) CreateShortLink(ctx context.Context, url *model.URL) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.idTaken(url.ID) {
+		return 0, ErrIDTaken
+	}
+	if url.Alias != "" {
+		if _, ok := r.aliases[url.Alias]; ok {
+			return 0, ErrAliasTaken
+		}
+	}
+	return r.insert(url), nil
+}
+
+func (r *MemURLRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch := make(map[int64]bool, len(urls))
+	for _, url := range urls {
+		if r.idTaken(url.ID) || batch[url.ID] {
+			return nil, ErrIDTaken
+		}
+		if url.ID != 0 {
+			batch[url.ID] = true
+		}
+	}
+	ids := make([]int64, len(urls))
+	for i, url := range urls {
+		if url.Alias != "" {
+			if _, ok := r.aliases[url.Alias]; ok {
+				continue
+			}
+		}
+		ids[i] = r.insert(url)
+	}
+	return ids, nil
+}
+
+// idTaken reports whether a pre-assigned ID is used by a link, even a deleted
+// one. The caller must hold mu.
+func (r *MemURLRepository) idTaken(id int64) bool {
+	_, ok := r.urls[id]
+	return ok || r.deleted[id]
+}
+
+// insert stores a copy of url under its ID, or the next one if it has none.
+// The caller must hold mu and have checked that the ID and alias are free.
+func (r *MemURLRepository) insert(url *model.URL) int64 {
+	stored := copyURL(url)
+	if stored.ID == 0 {
+		r.lastID++
+		stored.ID = r.lastID
+	}
+	// Like SQLite, generated IDs continue after the largest one used.
+	r.lastID = max(r.lastID, stored.ID)
+	stored.AccessCount = 0
+	stored.Version = 1
+	stored.UpdatedAt = nil
+	r.urls[stored.ID] = stored
+	if url.Alias != "" {
+		r.aliases[url.Alias] = stored.ID
+	}
+	if url.LongURLHash != "" && url.Alias == "" && url.ExpiresAt == nil {
+		r.hashes[url.LongURLHash] = append(r.hashes[url.LongURLHash], stored.ID)
+	}
+	return stored.ID
+}
+
+func (r *MemURLRepository) GetLongURL(ctx context.Context, id int64) (*model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	url, ok := r.urls[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &model.URL{ID: url.ID, LongURL: url.LongURL, ExpiresAt: url.ExpiresAt, Disabled: url.Disabled}, nil
+}
+
+func (r *MemURLRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.aliases[alias]
+	if _, exists := r.urls[id]; !ok || !exists {
+		return 0, ErrNotFound
+	}
+	return id, nil
+}
+
+func (r *MemURLRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.hashes[hash] {
+		// Skip links whose long URL was changed since.
+		if url, ok := r.urls[id]; ok && !url.Disabled && url.LongURLHash == hash && url.OwnerKeyID == ownerKeyID {
+			return id, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (r *MemURLRepository) GetURLStats(ctx context.Context, id int64) (*model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	url, ok := r.urls[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyURL(url), nil
+}
+
+func (r *MemURLRepository) UpdateLongURL(ctx context.Context, url *model.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.urls[url.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if url.Version != 0 && url.Version != stored.Version {
+		return ErrVersionMismatch
+	}
+
+	now := time.Now()
+	stored.LongURL = url.LongURL
+	stored.LongURLHash = url.LongURLHash
+	stored.Version++
+	stored.UpdatedAt = &now
+	if url.LongURLHash != "" && stored.Alias == "" && stored.ExpiresAt == nil {
+		r.hashes[url.LongURLHash] = insertSorted(r.hashes[url.LongURLHash], stored.ID)
+	}
+
+	url.Version = stored.Version
+	url.UpdatedAt = &now
+	return nil
+}
+
+func (r *MemURLRepository) DeleteShortLink(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.urls[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.urls, id)
+	r.deleted[id] = true
+	return nil
+}
+
+func (r *MemURLRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[id]
+	if !ok {
+		return ErrNotFound
+	}
+	url.Disabled = disabled
+	return nil
+}
+
+// IncrementAccessCount is a no-op for unknown IDs, like the UPDATE in PGURLRepository.
+func (r *MemURLRepository) IncrementAccessCount(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if url, ok := r.urls[id]; ok {
+		url.AccessCount++
+	}
+	return nil
+}
+
+func (r *MemURLRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, delta := range counts {
+		if url, ok := r.urls[id]; ok {
+			url.AccessCount += delta
+		}
+	}
+	return nil
+}
+
+// insertSorted adds id to the ascending ids, keeping them sorted.
+func insertSorted(ids []int64, id int64) []int64 {
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
+
+// copyURL returns a deep copy so callers can't mutate stored links.
+func copyURL(url *model.URL) *model.URL {
+	c := *url
+	if url.ExpiresAt != nil {
+		expiresAt := *url.ExpiresAt
+		c.ExpiresAt = &expiresAt
+	}
+	if url.UpdatedAt != nil {
+		updatedAt := *url.UpdatedAt
+		c.UpdatedAt = &updatedAt
+	}
+	return &c
+}
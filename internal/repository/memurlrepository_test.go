@@ -0,0 +1,129 @@
+package repository_test
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemURLRepository_CreateAndGet(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	id1, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/1"})
+	assert.NoError(t, err)
+	id2, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/2", Alias: "my-alias"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id1)
+	assert.Equal(t, int64(2), id2)
+
+	url, err := repo.GetLongURL(ctx, id1)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/1", url.LongURL)
+
+	id, err := repo.GetIDByAlias(ctx, "my-alias")
+	assert.NoError(t, err)
+	assert.Equal(t, id2, id)
+}
+
+func TestMemURLRepository_NotFound(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	_, err := repo.GetLongURL(ctx, 42)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetURLStats(ctx, 42)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetIDByAlias(ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestMemURLRepository_AliasTaken(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	_, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/1", Alias: "taken"})
+	assert.NoError(t, err)
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/2", Alias: "taken"})
+	assert.ErrorIs(t, err, repository.ErrAliasTaken)
+}
+
+func TestMemURLRepository_CreateShortLinks(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	ids, err := repo.CreateShortLinks(ctx, []*model.URL{
+		{LongURL: "http://example.com/1", Alias: "taken"},
+		{LongURL: "http://example.com/2", Alias: "taken"},
+		{LongURL: "http://example.com/3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 0, 2}, ids)
+}
+
+func TestMemURLRepository_PreassignedIDs(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{ID: 1 << 40, LongURL: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<40), id)
+	_, err = repo.CreateShortLink(ctx, &model.URL{ID: 1 << 40, LongURL: "http://example.com/2"})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+
+	_, err = repo.CreateShortLinks(ctx, []*model.URL{
+		{ID: 1<<40 + 1, LongURL: "http://example.com/3"},
+		{ID: 1<<40 + 1, LongURL: "http://example.com/4"},
+	})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+	_, err = repo.GetLongURL(ctx, 1<<40+1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestMemURLRepository_DeletedIDsStayTaken(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{ID: 7, LongURL: "http://example.com/1", Alias: "sale"})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DeleteShortLink(ctx, id))
+
+	_, err = repo.GetLongURL(ctx, id)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteShortLink(ctx, id), repository.ErrNotFound)
+	_, err = repo.CreateShortLink(ctx, &model.URL{ID: 7, LongURL: "http://example.com/2"})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+	_, err = repo.CreateShortLinks(ctx, []*model.URL{{ID: 7, LongURL: "http://example.com/3"}})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/4", Alias: "sale"})
+	assert.ErrorIs(t, err, repository.ErrAliasTaken)
+	id, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/5"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), id)
+}
+
+func TestMemURLRepository_ConcurrentIncrements(t *testing.T) {
+	repo := repository.NewMemURLRepository()
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com"})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.IncrementAccessCount(ctx, id))
+		}()
+	}
+	wg.Wait()
+
+	stats, err := repo.GetURLStats(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), stats.AccessCount)
+}
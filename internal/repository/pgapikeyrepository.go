@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shortlink-go/internal/model"
+)
+
+type PGAPIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewPGAPIKeyRepository(db *sql.DB) *PGAPIKeyRepository {
+	return &PGAPIKeyRepository{
+		DB: db,
+	}
+}
+
+func (r *PGAPIKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, "INSERT INTO api_keys (name, key_hash) VALUES ($1, $2) RETURNING id", key.Name, key.KeyHash).Scan(&id)
+	return id, err
+}
+
+func (r *PGAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.DB.QueryRowContext(ctx, "SELECT id, name, key_hash, created_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL", hash).
+		Scan(&key.ID, &key.Name, &key.KeyHash, &key.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *PGAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	return listAPIKeys(ctx, r.DB)
+}
+
+func (r *PGAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	err = requireRow(res)
+	if errors.Is(err, ErrNotFound) {
+		return ErrKeyNotFound
+	}
+	return err
+}
+
+// listAPIKeys runs the key listing query, which is the same for every SQL database.
+func listAPIKeys(ctx context.Context, db *sql.DB) ([]model.APIKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name, key_hash, created_at, revoked_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		var key model.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.KeyHash, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
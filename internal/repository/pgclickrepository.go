@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortlink-go/internal/model"
+	"time"
+)
+
+type PGClickRepository struct {
+	DB *sql.DB
+}
+
+func NewPGClickRepository(db *sql.DB) *PGClickRepository {
+	return &PGClickRepository{
+		DB: db,
+	}
+}
+
+func (r *PGClickRepository) RecordClicks(ctx context.Context, clicks []model.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	urlIDs := make([]int64, len(clicks))
+	clickedAt := make([]time.Time, len(clicks))
+	referrers := make([]string, len(clicks))
+	userAgents := make([]string, len(clicks))
+	ipHashes := make([]string, len(clicks))
+	languages := make([]string, len(clicks))
+	for i, c := range clicks {
+		urlIDs[i] = c.URLID
+		clickedAt[i] = c.ClickedAt
+		referrers[i] = c.Referrer
+		userAgents[i] = c.UserAgent
+		ipHashes[i] = c.IPHash
+		languages[i] = c.AcceptLanguage
+	}
+
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, ip_hash, accept_language)
+		SELECT * FROM unnest($1::bigint[], $2::timestamptz[], $3::text[], $4::text[], $5::text[], $6::text[])`,
+		urlIDs, clickedAt, referrers, userAgents, ipHashes, languages)
+	return err
+}
+
+func (r *PGClickRepository) CountClicks(ctx context.Context, urlID int64, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT date_trunc($2, clicked_at AT TIME ZONE $3) AT TIME ZONE $3 AS bucket, count(*)
+		FROM clicks WHERE url_id = $1 AND clicked_at >= $4 AND clicked_at < $5
+		GROUP BY bucket ORDER BY bucket`, urlID, string(interval), loc.String(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []model.ClickBucket
+	for rows.Next() {
+		var b model.ClickBucket
+		if err := rows.Scan(&b.Start, &b.Clicks); err != nil {
+			return nil, err
+		}
+		b.Start = b.Start.In(loc)
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
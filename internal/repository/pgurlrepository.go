@@ -5,8 +5,18 @@ import (
 	"database/sql"
 	"errors"
 	"shortlink-go/internal/model"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// pgUniqueViolation is the Postgres error code for unique constraint violations.
+const pgUniqueViolation = "23505"
+
+// idBlockLockID is the key of the Postgres advisory lock held while reserving a
+// block of IDs.
+const idBlockLockID = 7207362
+
 type PGURLRepository struct {
 	DB *sql.DB
 }
@@ -17,40 +27,261 @@ func NewPGURLRepository(db *sql.DB) *PGURLRepository {
 	}
 }
 
-func (r *PGURLRepository) CreateShortLink(ctx context.Context, longURL string) (int64, error) {
+func (r *PGURLRepository) CreateShortLink(ctx context.Context, url *model.URL) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `INSERT INTO urls (id, long_url, alias, access_count, expires_at, long_url_hash, owner_key_id)
+		VALUES (COALESCE($1, nextval(pg_get_serial_sequence('urls', 'id'))), $2, $3, $4, $5, $6, $7) RETURNING id`,
+		nullInt64(url.ID), url.LongURL, nullString(url.Alias), 0, url.ExpiresAt, nullString(url.LongURLHash), nullInt64(url.OwnerKeyID)).Scan(&id)
+	if err != nil {
+		return 0, pgInsertError(err)
+	}
+	return id, nil
+}
+
+// pgInsertError maps unique violations of an insert into urls to ErrIDTaken or ErrAliasTaken.
+func pgInsertError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		if pgErr.ConstraintName == "urls_pkey" {
+			return ErrIDTaken
+		}
+		return ErrAliasTaken
+	}
+	return err
+}
+
+// CreateShortLinks reserves the IDs not assigned yet up front, so it can tell
+// from the rows returned by a single multi-row INSERT which links were skipped
+// for a taken alias.
+func (r *PGURLRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error) {
+	ids := make([]int64, len(urls))
+	missing := 0
+	for i, url := range urls {
+		ids[i] = url.ID
+		if url.ID == 0 {
+			missing++
+		}
+	}
+	if missing > 0 {
+		rows, err := r.DB.QueryContext(ctx, "SELECT nextval(pg_get_serial_sequence('urls', 'id')) FROM generate_series(1, $1)", missing)
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		for rows.Next() {
+			for ids[i] != 0 {
+				i++
+			}
+			if err := rows.Scan(&ids[i]); err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	longURLs := make([]string, len(urls))
+	aliases := make([]*string, len(urls))
+	expiresAt := make([]*time.Time, len(urls))
+	hashes := make([]*string, len(urls))
+	owners := make([]int64, len(urls))
+	for i, url := range urls {
+		longURLs[i] = url.LongURL
+		if url.Alias != "" {
+			aliases[i] = &url.Alias
+		}
+		expiresAt[i] = url.ExpiresAt
+		if url.LongURLHash != "" {
+			hashes[i] = &url.LongURLHash
+		}
+		owners[i] = url.OwnerKeyID
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `INSERT INTO urls (id, long_url, alias, access_count, expires_at, long_url_hash, owner_key_id)
+		SELECT id, long_url, alias, 0, expires_at, long_url_hash, NULLIF(owner_key_id, 0)
+		FROM unnest($1::bigint[], $2::text[], $3::text[], $4::timestamptz[], $5::text[], $6::bigint[])
+			AS batch(id, long_url, alias, expires_at, long_url_hash, owner_key_id)
+		ON CONFLICT (alias) DO NOTHING
+		RETURNING id`, ids, longURLs, aliases, expiresAt, hashes, owners)
+	if err != nil {
+		return nil, pgInsertError(err)
+	}
+	defer rows.Close()
+
+	inserted := make(map[int64]bool, len(urls))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		inserted[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if !inserted[id] {
+			ids[i] = 0
+		}
+	}
+	return ids, nil
+}
+
+// ReserveIDs reserves n consecutive IDs from the urls ID sequence and returns
+// the first one. The advisory lock keeps concurrent reservations from
+// overlapping, so all instances must reserve IDs rather than insert without
+// one while any of them does.
+func (r *PGURLRepository) ReserveIDs(ctx context.Context, n int64) (int64, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", idBlockLockID); err != nil {
+		return 0, err
+	}
+	var first int64
+	if err := tx.QueryRowContext(ctx, "SELECT nextval(pg_get_serial_sequence('urls', 'id'))").Scan(&first); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence('urls', 'id'), $1)", first+n-1); err != nil {
+		return 0, err
+	}
+	return first, tx.Commit()
+}
+
+// GetLongURL returns the fields needed to redirect a link: its long URL, expiry
+// and whether it is disabled.
+func (r *PGURLRepository) GetLongURL(ctx context.Context, id int64) (*model.URL, error) {
+	url := model.URL{ID: id}
+	err := r.DB.QueryRowContext(ctx, "SELECT long_url, expires_at, disabled FROM urls WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&url.LongURL, &url.ExpiresAt, &url.Disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &url, nil
+}
+
+func (r *PGURLRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
 	var id int64
-	err := r.DB.QueryRowContext(ctx, "INSERT INTO urls (long_url, access_count) VALUES ($1, $2) RETURNING id", longURL, 0).Scan(&id)
+	err := r.DB.QueryRowContext(ctx, "SELECT id FROM urls WHERE alias = $1 AND deleted_at IS NULL", alias).Scan(&id)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *PGURLRepository) GetLongURL(ctx context.Context, id int64) (string, error) {
-	var longURL string
-	err := r.DB.QueryRowContext(ctx, "SELECT long_url FROM urls WHERE id = $1", id).Scan(&longURL)
+func (r *PGURLRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `SELECT id FROM urls
+		WHERE long_url_hash = $1 AND owner_key_id IS NOT DISTINCT FROM $2
+			AND alias IS NULL AND expires_at IS NULL AND NOT disabled AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`, hash, nullInt64(ownerKeyID)).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", errors.New("no URL found")
+			return 0, ErrNotFound
 		}
-		return "", err
+		return 0, err
 	}
-	return longURL, nil
+	return id, nil
 }
 
 func (r *PGURLRepository) GetURLStats(ctx context.Context, id int64) (*model.URL, error) {
 	var url model.URL
-	err := r.DB.QueryRowContext(ctx, "SELECT id, long_url, access_count FROM urls WHERE id = $1", id).Scan(&url.ID, &url.LongURL, &url.AccessCount)
+	err := r.DB.QueryRowContext(ctx, `SELECT id, long_url, COALESCE(alias, ''), access_count, expires_at, COALESCE(long_url_hash, ''), disabled, version, updated_at,
+			COALESCE(owner_key_id, 0)
+		FROM urls WHERE id = $1 AND deleted_at IS NULL`, id).
+		Scan(&url.ID, &url.LongURL, &url.Alias, &url.AccessCount, &url.ExpiresAt, &url.LongURLHash, &url.Disabled, &url.Version, &url.UpdatedAt, &url.OwnerKeyID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("no URL found")
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 	return &url, nil
 }
 
+func (r *PGURLRepository) UpdateLongURL(ctx context.Context, url *model.URL) error {
+	err := r.DB.QueryRowContext(ctx, `UPDATE urls SET long_url = $2, long_url_hash = $3, version = version + 1, updated_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+		RETURNING version, updated_at`,
+		url.ID, url.LongURL, nullString(url.LongURLHash), url.Version).Scan(&url.Version, &url.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// Tell a missing link from one that was edited in the meantime.
+		var exists bool
+		if err := r.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM urls WHERE id = $1 AND deleted_at IS NULL)", url.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionMismatch
+		}
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *PGURLRepository) DeleteShortLink(ctx context.Context, id int64) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *PGURLRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET disabled = $2 WHERE id = $1 AND deleted_at IS NULL", id, disabled)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
 func (r *PGURLRepository) IncrementAccessCount(ctx context.Context, id int64) error {
 	_, err := r.DB.ExecContext(ctx, "UPDATE urls SET access_count = access_count + 1 WHERE id = $1", id)
 	return err
 }
+
+func (r *PGURLRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	ids := make([]int64, 0, len(counts))
+	deltas := make([]int64, 0, len(counts))
+	for id, delta := range counts {
+		ids = append(ids, id)
+		deltas = append(deltas, delta)
+	}
+	_, err := r.DB.ExecContext(ctx, `UPDATE urls SET access_count = urls.access_count + batch.delta
+		FROM unnest($1::bigint[], $2::bigint[]) AS batch(id, delta)
+		WHERE urls.id = batch.id`, ids, deltas)
+	return err
+}
+
+// requireRow returns ErrNotFound if a statement affected no rows.
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// nullInt64 maps 0 to SQL NULL, for optional references to other tables.
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+// nullString maps an empty string to SQL NULL so optional unique columns don't collide.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shortlink-go/internal/model"
+	"time"
+)
+
+type SQLiteAPIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteAPIKeyRepository(db *sql.DB) *SQLiteAPIKeyRepository {
+	return &SQLiteAPIKeyRepository{
+		DB: db,
+	}
+}
+
+func (r *SQLiteAPIKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, "INSERT INTO api_keys (name, key_hash, created_at) VALUES (?, ?, ?) RETURNING id",
+		key.Name, key.KeyHash, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+func (r *SQLiteAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.DB.QueryRowContext(ctx, "SELECT id, name, key_hash, created_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL", hash).
+		Scan(&key.ID, &key.Name, &key.KeyHash, &key.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *SQLiteAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	return listAPIKeys(ctx, r.DB)
+}
+
+func (r *SQLiteAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	err = requireRow(res)
+	if errors.Is(err, ErrNotFound) {
+		return ErrKeyNotFound
+	}
+	return err
+}
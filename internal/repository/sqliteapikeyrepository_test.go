@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"shortlink-go/config"
+	"shortlink-go/internal/database"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteAPIKeyRepository(t *testing.T) {
+	db := database.NewDB(&config.Config{
+		StorageDriver: config.StorageSQLite,
+		SQLitePath:    filepath.Join(t.TempDir(), "test.db"),
+		DBAutoMigrate: true,
+	})
+	t.Cleanup(func() { db.Close() })
+	repo := repository.NewSQLiteAPIKeyRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.CreateAPIKey(ctx, &model.APIKey{Name: "ci", KeyHash: "h1"})
+	assert.NoError(t, err)
+	_, err = repo.CreateAPIKey(ctx, &model.APIKey{Name: "blog", KeyHash: "h2"})
+	assert.NoError(t, err)
+
+	key, err := repo.GetAPIKeyByHash(ctx, "h1")
+	assert.NoError(t, err)
+	assert.Equal(t, id, key.ID)
+	assert.Equal(t, "ci", key.Name)
+	assert.False(t, key.CreatedAt.IsZero())
+	_, err = repo.GetAPIKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, repository.ErrKeyNotFound)
+
+	// Revoked keys can't authenticate but are still listed.
+	assert.NoError(t, repo.RevokeAPIKey(ctx, id))
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, id), repository.ErrKeyNotFound)
+	_, err = repo.GetAPIKeyByHash(ctx, "h1")
+	assert.ErrorIs(t, err, repository.ErrKeyNotFound)
+	keys, err := repo.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.NotNil(t, keys[0].RevokedAt)
+		assert.Nil(t, keys[1].RevokedAt)
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortlink-go/internal/model"
+	"time"
+)
+
+type SQLiteClickRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteClickRepository(db *sql.DB) *SQLiteClickRepository {
+	return &SQLiteClickRepository{
+		DB: db,
+	}
+}
+
+func (r *SQLiteClickRepository) RecordClicks(ctx context.Context, clicks []model.Click) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, ip_hash, accept_language)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		_, err := stmt.ExecContext(ctx, c.URLID, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.IPHash, c.AcceptLanguage)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountClicks buckets in Go, as SQLite has no time zone support.
+func (r *SQLiteClickRepository) CountClicks(ctx context.Context, urlID int64, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT clicked_at FROM clicks WHERE url_id = ? AND clicked_at >= ? AND clicked_at < ?",
+		urlID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bucketClicks(times, interval, loc), nil
+}
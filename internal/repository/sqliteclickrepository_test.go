@@ -0,0 +1,37 @@
+package repository_test
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteClickRepository_CountClicks(t *testing.T) {
+	urlRepo := newSQLiteRepository(t)
+	clickRepo := repository.NewSQLiteClickRepository(urlRepo.DB)
+	ctx := context.Background()
+
+	id, err := urlRepo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com"})
+	assert.NoError(t, err)
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	at := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 30, 0, 0, time.UTC) }
+	assert.NoError(t, clickRepo.RecordClicks(ctx, []model.Click{
+		{URLID: id, ClickedAt: at(1, 10)}, // March 1st in Tokyo.
+		{URLID: id, ClickedAt: at(1, 16)}, // March 2nd in Tokyo.
+		{URLID: id, ClickedAt: at(2, 1)},  // March 2nd in Tokyo.
+		{URLID: id, ClickedAt: at(9, 1)},  // Out of range.
+	}))
+
+	buckets, err := clickRepo.CountClicks(ctx, id, at(1, 0), at(3, 0), model.Day, tokyo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClickBucket{
+		{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, tokyo), Clicks: 1},
+		{Start: time.Date(2024, 3, 2, 0, 0, 0, 0, tokyo), Clicks: 2},
+	}, buckets)
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shortlink-go/internal/model"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteURLRepository stores links in an embedded SQLite database for small
+// self-hosted installs.
+type SQLiteURLRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteURLRepository(db *sql.DB) *SQLiteURLRepository {
+	return &SQLiteURLRepository{
+		DB: db,
+	}
+}
+
+func (r *SQLiteURLRepository) CreateShortLink(ctx context.Context, url *model.URL) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `INSERT INTO urls (id, long_url, alias, access_count, expires_at, long_url_hash, owner_key_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		nullInt64(url.ID), url.LongURL, nullString(url.Alias), 0, utcTime(url.ExpiresAt), nullString(url.LongURLHash), nullInt64(url.OwnerKeyID)).Scan(&id)
+	if err != nil {
+		return 0, sqliteInsertError(err)
+	}
+	return id, nil
+}
+
+// sqliteInsertError maps constraint violations of an insert into urls to
+// ErrIDTaken or ErrAliasTaken.
+func sqliteInsertError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ErrIDTaken
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+			return ErrAliasTaken
+		}
+	}
+	return err
+}
+
+func (r *SQLiteURLRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO urls (id, long_url, alias, access_count, expires_at, long_url_hash, owner_key_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (alias) DO NOTHING RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, len(urls))
+	for i, url := range urls {
+		err := stmt.QueryRowContext(ctx, nullInt64(url.ID), url.LongURL, nullString(url.Alias), 0, utcTime(url.ExpiresAt), nullString(url.LongURLHash), nullInt64(url.OwnerKeyID)).Scan(&ids[i])
+		if err != nil && err != sql.ErrNoRows { // No row means the alias is taken.
+			return nil, sqliteInsertError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetLongURL returns the fields needed to redirect a link: its long URL, expiry
+// and whether it is disabled.
+func (r *SQLiteURLRepository) GetLongURL(ctx context.Context, id int64) (*model.URL, error) {
+	url := model.URL{ID: id}
+	err := r.DB.QueryRowContext(ctx, "SELECT long_url, expires_at, disabled FROM urls WHERE id = ? AND deleted_at IS NULL", id).
+		Scan(&url.LongURL, &url.ExpiresAt, &url.Disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &url, nil
+}
+
+func (r *SQLiteURLRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, "SELECT id FROM urls WHERE alias = ? AND deleted_at IS NULL", alias).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *SQLiteURLRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `SELECT id FROM urls
+		WHERE long_url_hash = ? AND owner_key_id IS ?
+			AND alias IS NULL AND expires_at IS NULL AND NOT disabled AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`, hash, nullInt64(ownerKeyID)).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *SQLiteURLRepository) GetURLStats(ctx context.Context, id int64) (*model.URL, error) {
+	var url model.URL
+	err := r.DB.QueryRowContext(ctx, `SELECT id, long_url, COALESCE(alias, ''), access_count, expires_at, COALESCE(long_url_hash, ''), disabled, version, updated_at,
+			COALESCE(owner_key_id, 0)
+		FROM urls WHERE id = ? AND deleted_at IS NULL`, id).
+		Scan(&url.ID, &url.LongURL, &url.Alias, &url.AccessCount, &url.ExpiresAt, &url.LongURLHash, &url.Disabled, &url.Version, &url.UpdatedAt, &url.OwnerKeyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &url, nil
+}
+
+func (r *SQLiteURLRepository) UpdateLongURL(ctx context.Context, url *model.URL) error {
+	err := r.DB.QueryRowContext(ctx, `UPDATE urls SET long_url = ?, long_url_hash = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+		RETURNING version, updated_at`,
+		url.LongURL, nullString(url.LongURLHash), time.Now().UTC(), url.ID, url.Version, url.Version).Scan(&url.Version, &url.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// Tell a missing link from one that was edited in the meantime.
+		var exists bool
+		if err := r.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM urls WHERE id = ? AND deleted_at IS NULL)", url.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionMismatch
+		}
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *SQLiteURLRepository) DeleteShortLink(ctx context.Context, id int64) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLiteURLRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET disabled = ? WHERE id = ? AND deleted_at IS NULL", disabled, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLiteURLRepository) IncrementAccessCount(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE urls SET access_count = access_count + 1 WHERE id = ?", id)
+	return err
+}
+
+func (r *SQLiteURLRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "UPDATE urls SET access_count = access_count + ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, delta := range counts {
+		if _, err := stmt.ExecContext(ctx, delta, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// utcTime normalizes times to UTC, as SQLite stores them as text and compares them lexically.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,203 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"shortlink-go/config"
+	"shortlink-go/internal/database"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLiteRepository(t *testing.T) *repository.SQLiteURLRepository {
+	db := database.NewDB(&config.Config{
+		StorageDriver: config.StorageSQLite,
+		SQLitePath:    filepath.Join(t.TempDir(), "test.db"),
+		DBAutoMigrate: true,
+	})
+	t.Cleanup(func() { db.Close() })
+	return repository.NewSQLiteURLRepository(db)
+}
+
+func TestSQLiteURLRepository_CreateAndGet(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", Alias: "my-alias", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	url, err := repo.GetLongURL(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com", url.LongURL)
+	assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+
+	aliasID, err := repo.GetIDByAlias(ctx, "my-alias")
+	assert.NoError(t, err)
+	assert.Equal(t, id, aliasID)
+
+	assert.NoError(t, repo.IncrementAccessCount(ctx, id))
+	assert.NoError(t, repo.IncrementAccessCounts(ctx, map[int64]int64{id: 4, 99: 1}))
+	stats, err := repo.GetURLStats(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stats.AccessCount)
+	assert.Equal(t, "my-alias", stats.Alias)
+}
+
+func TestSQLiteURLRepository_Errors(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.GetLongURL(ctx, 42)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/1", Alias: "taken"})
+	assert.NoError(t, err)
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/2", Alias: "taken"})
+	assert.ErrorIs(t, err, repository.ErrAliasTaken)
+}
+
+func TestSQLiteURLRepository_CreateShortLinks(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/1", Alias: "taken"})
+	assert.NoError(t, err)
+
+	ids, err := repo.CreateShortLinks(ctx, []*model.URL{
+		{LongURL: "http://example.com/2"},
+		{LongURL: "http://example.com/3", Alias: "taken"},
+		{LongURL: "http://example.com/4", Alias: "new-alias"},
+		{LongURL: "http://example.com/5", Alias: "new-alias"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, ids, 4)
+	assert.Zero(t, ids[1])
+	assert.Zero(t, ids[3])
+
+	// Skipped rows may leave gaps in the IDs, so only check they map to the right links.
+	url, err := repo.GetLongURL(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/2", url.LongURL)
+	aliasID, err := repo.GetIDByAlias(ctx, "new-alias")
+	assert.NoError(t, err)
+	assert.Equal(t, ids[2], aliasID)
+}
+
+func TestSQLiteURLRepository_PreassignedIDs(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{ID: 1 << 40, LongURL: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<40), id)
+	_, err = repo.CreateShortLink(ctx, &model.URL{ID: 1 << 40, LongURL: "http://example.com/2"})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+
+	ids, err := repo.CreateShortLinks(ctx, []*model.URL{
+		{ID: 1<<40 + 1, LongURL: "http://example.com/3"},
+		{LongURL: "http://example.com/4"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<40+1), ids[0])
+	assert.Equal(t, int64(1<<40+2), ids[1])
+	_, err = repo.CreateShortLinks(ctx, []*model.URL{{ID: 1 << 40, LongURL: "http://example.com/5"}})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+}
+
+func TestSQLiteURLRepository_GetIDByLongURLHash(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	// Links with an alias or expiry are never reused.
+	_, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", LongURLHash: "h", Alias: "my-alias"})
+	assert.NoError(t, err)
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", LongURLHash: "h", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	_, err = repo.GetIDByLongURLHash(ctx, "h", 0)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	ids, err := repo.CreateShortLinks(ctx, []*model.URL{
+		{LongURL: "http://example.com", LongURLHash: "h"},
+		{LongURL: "http://example.com", LongURLHash: "h"},
+	})
+	assert.NoError(t, err)
+	id, err := repo.GetIDByLongURLHash(ctx, "h", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], id)
+
+	// Links are only reused for the API key that created them.
+	_, err = repo.GetIDByLongURLHash(ctx, "h", 7)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	ownedID, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", LongURLHash: "h", OwnerKeyID: 7})
+	assert.NoError(t, err)
+	id, err = repo.GetIDByLongURLHash(ctx, "h", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, ownedID, id)
+	url, err := repo.GetURLStats(ctx, ownedID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), url.OwnerKeyID)
+}
+
+func TestSQLiteURLRepository_DeleteAndDisable(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", Alias: "my-alias", LongURLHash: "h"})
+	assert.NoError(t, err)
+	plainID, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", LongURLHash: "h"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.SetDisabled(ctx, plainID, true))
+	url, err := repo.GetLongURL(ctx, plainID)
+	assert.NoError(t, err)
+	assert.True(t, url.Disabled)
+	_, err = repo.GetIDByLongURLHash(ctx, "h", 0)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, repo.SetDisabled(ctx, plainID, false))
+	_, err = repo.GetIDByLongURLHash(ctx, "h", 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.DeleteShortLink(ctx, id))
+	_, err = repo.GetLongURL(ctx, id)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetIDByAlias(ctx, "my-alias")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteShortLink(ctx, id), repository.ErrNotFound)
+	assert.ErrorIs(t, repo.SetDisabled(ctx, id, true), repository.ErrNotFound)
+
+	// The alias of a deleted link stays taken.
+	_, err = repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com", Alias: "my-alias"})
+	assert.ErrorIs(t, err, repository.ErrAliasTaken)
+}
+
+func TestSQLiteURLRepository_UpdateLongURL(t *testing.T) {
+	repo := newSQLiteRepository(t)
+	ctx := context.Background()
+
+	id, err := repo.CreateShortLink(ctx, &model.URL{LongURL: "http://example.com/old", LongURLHash: "old"})
+	assert.NoError(t, err)
+
+	url := &model.URL{ID: id, LongURL: "http://example.com/new", LongURLHash: "new", Version: 1}
+	assert.NoError(t, repo.UpdateLongURL(ctx, url))
+	assert.Equal(t, int64(2), url.Version)
+	assert.NotNil(t, url.UpdatedAt)
+
+	stats, err := repo.GetURLStats(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/new", stats.LongURL)
+	assert.Equal(t, int64(2), stats.Version)
+	_, err = repo.GetIDByLongURLHash(ctx, "old", 0)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// A stale version fails, while version 0 skips the check.
+	assert.ErrorIs(t, repo.UpdateLongURL(ctx, &model.URL{ID: id, LongURL: "http://example.com/x", Version: 1}), repository.ErrVersionMismatch)
+	assert.NoError(t, repo.UpdateLongURL(ctx, &model.URL{ID: id, LongURL: "http://example.com/x"}))
+	assert.ErrorIs(t, repo.UpdateLongURL(ctx, &model.URL{ID: 99, LongURL: "http://example.com/x"}), repository.ErrNotFound)
+}
@@ -2,12 +2,45 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"shortlink-go/internal/model"
 )
 
+var (
+	ErrNotFound   = errors.New("no URL found")
+	ErrAliasTaken = errors.New("alias already taken")
+	// ErrIDTaken means a pre-assigned ID was already used, e.g. by two ID
+	// generators sharing a node ID.
+	ErrIDTaken = errors.New("ID already taken")
+	// ErrVersionMismatch means a link was edited since the version the caller read.
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
 type URLRepository interface {
-	CreateShortLink(ctx context.Context, longURL string) (int64, error)
-	GetLongURL(ctx context.Context, id int64) (string, error)
+	// CreateShortLink inserts url and returns its ID, which is url.ID if set or
+	// assigned by the database otherwise.
+	CreateShortLink(ctx context.Context, url *model.URL) (int64, error)
+	// CreateShortLinks inserts urls in one batch and returns their IDs in the same
+	// order, using url.ID where set. The ID is 0 for links whose alias is already
+	// taken; other failures abort the whole batch.
+	CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error)
+	GetLongURL(ctx context.Context, id int64) (*model.URL, error)
+	GetIDByAlias(ctx context.Context, alias string) (int64, error)
+	// GetIDByLongURLHash returns the oldest enabled link of the given owner with
+	// the given LongURLHash that has neither an alias nor an expiry.
+	GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error)
 	GetURLStats(ctx context.Context, id int64) (*model.URL, error)
+	// UpdateLongURL sets the long URL and LongURLHash of the link with url.ID if
+	// its version is still url.Version, or regardless when url.Version is 0. On
+	// success url.Version and url.UpdatedAt are set to the new values.
+	UpdateLongURL(ctx context.Context, url *model.URL) error
+	// DeleteShortLink soft-deletes a link: it is no longer found, but its ID and
+	// alias are never reused.
+	DeleteShortLink(ctx context.Context, id int64) error
+	// SetDisabled disables or re-enables a link. Disabled links are still found,
+	// with Disabled set.
+	SetDisabled(ctx context.Context, id int64, disabled bool) error
 	IncrementAccessCount(ctx context.Context, id int64) error
+	// IncrementAccessCounts adds counts[id] to the access count of each URL in one batch.
+	IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error
 }
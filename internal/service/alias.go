@@ -0,0 +1,38 @@
+package service
+
+import (
+	"shortlink-go/internal/shortcode"
+	"strings"
+)
+
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 64
+
+	// maxCodeLength is the length of the largest generated short link.
+	// Purely alphanumeric codes up to this length belong to the generated ID
+	// space and can't be used as aliases.
+	maxCodeLength = shortcode.MaxLength
+)
+
+// IsAlias reports whether a short link is a custom alias rather than a
+// base62-encoded database ID.
+func IsAlias(shortLink string) bool {
+	return len(shortLink) > maxCodeLength || strings.ContainsAny(shortLink, "-_")
+}
+
+// ValidAlias reports whether alias uses the allowed alphabet and length and
+// can't be mistaken for a generated short link.
+func ValidAlias(alias string) bool {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return false
+	}
+	for _, c := range alias {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return IsAlias(alias)
+}
@@ -3,10 +3,17 @@ package service
 import (
 	"context"
 	"shortlink-go/internal/model"
+	"time"
 )
 
 type IService interface {
-	CreateShortLink(ctx context.Context, longURL string) (string, error)
-	GetLongURL(ctx context.Context, shortLink string) (string, error)
+	CreateShortLink(ctx context.Context, longURL string, opts LinkOptions) (string, error)
+	CreateShortLinks(ctx context.Context, links []BatchLink) ([]BatchResult, error)
+	GetLongURL(ctx context.Context, shortLink string) (longURL string, id int64, err error)
+	UpdateLongURL(ctx context.Context, shortLink, longURL string, version int64) (*model.URL, error)
+	DeleteLink(ctx context.Context, shortLink string) error
+	SetLinkDisabled(ctx context.Context, shortLink string, disabled bool) error
 	GetLinkStats(ctx context.Context, shortLink string) (*model.URL, error)
+	GetClickTimeSeries(ctx context.Context, shortLink string, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error)
+	RecordClick(ctx context.Context, id int64, click model.Click, clientIP string)
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NormalizeURL returns the canonical form of a long URL used to detect
+// duplicates: the scheme and host are lowercased, default ports are dropped and
+// an empty path becomes "/". The rest of the URL is kept as is, as servers may
+// treat it case-sensitively. Unparsable URLs are returned unchanged.
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"): // IPv6 literal
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// hashURL returns the LongURLHash of a long URL.
+func hashURL(longURL string) string {
+	sum := sha256.Sum256([]byte(NormalizeURL(longURL)))
+	return hex.EncodeToString(sum[:])
+}
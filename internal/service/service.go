@@ -3,82 +3,667 @@ package service
 import (
 	"context"
 	"errors"
-	"log"
+	"shortlink-go/internal/auth"
 	"shortlink-go/internal/cache"
+	"shortlink-go/internal/logging"
+	"shortlink-go/internal/metrics"
 	"shortlink-go/internal/model"
 	"shortlink-go/internal/repository"
-	"shortlink-go/pkg/base62"
+	"shortlink-go/internal/shortcode"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const REDIS_KEY_PREFIX = "shortlink:"
+const REDIS_ALIAS_KEY_PREFIX = REDIS_KEY_PREFIX + "alias:"
+const REDIS_URL_KEY_PREFIX = REDIS_KEY_PREFIX + "url:"
+
+var (
+	ErrShortLinkNotFound = errors.New("short link not found")
+	ErrInvalidAlias      = errors.New("invalid alias")
+	ErrAliasTaken        = errors.New("alias already taken")
+	ErrShortLinkExpired  = errors.New("short link expired")
+	ErrShortLinkDisabled = errors.New("short link disabled")
+	ErrVersionMismatch   = errors.New("short link was modified")
+	ErrInvalidExpiry     = errors.New("expiry must be in the future")
+	ErrInvalidRange      = errors.New("invalid time range")
+	ErrBatchTooLarge     = errors.New("too many links in batch")
+)
+
+// MaxTimeSeriesBuckets bounds the size of a time series response.
+const MaxTimeSeriesBuckets = 5000
+
+// MaxBatchSize bounds the number of links created in one batch.
+const MaxBatchSize = 1000
+
+// Options holds the settings of a Service.
+type Options struct {
+	// Dedupe makes new links reuse the short link of an existing link to the same
+	// normalized URL, unless LinkOptions.Dedupe says otherwise.
+	Dedupe bool
+	// Codec turns link IDs into short links and back. Defaults to
+	// shortcode.Sequential.
+	Codec shortcode.Codec
+	// IDGenerator assigns the IDs of new links. When nil, the database does.
+	IDGenerator IDGenerator
+	// CacheTTL bounds how long entries are kept in Redis, so one that failed to
+	// be evicted doesn't stay stale forever. 0 keeps them until evicted.
+	CacheTTL time.Duration
+}
+
+// LinkOptions holds the optional settings of a new short link.
+type LinkOptions struct {
+	Alias     string
+	ExpiresAt *time.Time // Nil for links that never expire.
+	// Dedupe overrides Options.Dedupe when set. Links with an alias or expiry
+	// are never deduplicated.
+	Dedupe *bool
+}
+
+// BatchLink is one link to create with CreateShortLinks.
+type BatchLink struct {
+	LongURL string
+	Options LinkOptions
+}
+
+// BatchResult is the outcome of creating one BatchLink: its short link, or the
+// error that kept it from being created.
+type BatchResult struct {
+	ShortLink string
+	Err       error
+}
+
+// AccessCounter records link accesses off the request path, e.g. counter.Aggregator.
+type AccessCounter interface {
+	Add(ctx context.Context, id int64) error
+}
+
+// PendingCounter is an AccessCounter that can report accesses not yet written
+// to the database, e.g. counter.RedisCounter.
+type PendingCounter interface {
+	AccessCounter
+	Pending(ctx context.Context, id int64) (int64, error)
+}
+
+// IDGenerator assigns IDs to new links without a round trip to the database,
+// e.g. idgen.Snowflake.
+type IDGenerator interface {
+	NextID(ctx context.Context) (int64, error)
+}
 
-var ErrShortLinkNotFound = errors.New("short link not found")
+// ClickRecorder stores click events off the request path, e.g. analytics.Recorder.
+type ClickRecorder interface {
+	Record(click model.Click, clientIP string)
+}
 
 type Service struct {
-	urlRepo     repository.URLRepository
-	redisClient cache.RedisClient
+	urlRepo       repository.URLRepository
+	clickRepo     repository.ClickRepository
+	redisClient   cache.RedisClient
+	accessCounter AccessCounter
+	clickRecorder ClickRecorder // Nil when click recording is disabled.
+	opts          Options
+	codec         shortcode.Codec
 }
 
-func NewService(urlRepo repository.URLRepository, redisClient cache.RedisClient) *Service {
+func NewService(urlRepo repository.URLRepository, clickRepo repository.ClickRepository, redisClient cache.RedisClient, accessCounter AccessCounter, clickRecorder ClickRecorder, opts Options) *Service {
+	codec := opts.Codec
+	if codec == nil {
+		codec = shortcode.Sequential{}
+	}
 	return &Service{
-		urlRepo:     urlRepo,
-		redisClient: redisClient,
+		urlRepo:       urlRepo,
+		clickRepo:     clickRepo,
+		redisClient:   redisClient,
+		accessCounter: accessCounter,
+		clickRecorder: clickRecorder,
+		opts:          opts,
+		codec:         codec,
 	}
 }
 
 // Inserts a new URL into the database and returns the short link.
-func (s *Service) CreateShortLink(ctx context.Context, longURL string) (string, error) {
+func (s *Service) CreateShortLink(ctx context.Context, longURL string, opts LinkOptions) (string, error) {
+	if err := validateOptions(opts); err != nil {
+		return "", err
+	}
+
+	// Reuse the existing short link when deduplicating.
+	hash := hashURL(longURL)
+	dedupe := s.dedupe(opts)
+	if dedupe {
+		id, err := s.findDuplicate(ctx, hash)
+		if err == nil {
+			return s.codec.Encode(id), nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return "", err
+		}
+	}
+
 	// Insert the long URL into the database and get the ID.
-	id, err := s.urlRepo.CreateShortLink(ctx, longURL)
+	owner := ownerKeyID(ctx)
+	url := &model.URL{LongURL: longURL, Alias: opts.Alias, ExpiresAt: opts.ExpiresAt, LongURLHash: hash, OwnerKeyID: owner}
+	if err := s.assignIDs(ctx, url); err != nil {
+		return "", err
+	}
+	id, err := s.urlRepo.CreateShortLink(ctx, url)
 	if err != nil {
+		if errors.Is(err, repository.ErrAliasTaken) {
+			return "", ErrAliasTaken
+		}
 		return "", err
 	}
 
-	shortLink := base62.Encode(id) // Encode the ID to base62 to get the short link.
+	shortLink := s.codec.Encode(id) // Encode the ID to get the short link.
 
-	// Cache the long URL in Redis using the short link as the key.
-	err = s.redisClient.Set(ctx, REDIS_KEY_PREFIX+shortLink, longURL, 0).Err()
+	// Cache the long URL in Redis using the short link as the key, for as long as the link lives.
+	ttl := s.cacheTTL(opts.ExpiresAt)
+	err = s.redisClient.Set(ctx, REDIS_KEY_PREFIX+shortLink, longURL, ttl).Err()
 	if err != nil {
-		log.Printf("Error caching short link in Redis: %v", err)
+		warnRedis(ctx, "Failed to cache short link in Redis", err, "short_link", shortLink)
+	}
+
+	if dedupe {
+		err = s.redisClient.Set(ctx, dedupeKey(owner, hash), id, s.cacheTTL(nil)).Err()
+		if err != nil {
+			warnRedis(ctx, "Failed to cache long URL hash in Redis", err, "id", id)
+		}
+	}
+
+	if opts.Alias != "" {
+		err = s.redisClient.Set(ctx, REDIS_ALIAS_KEY_PREFIX+opts.Alias, id, ttl).Err()
+		if err != nil {
+			warnRedis(ctx, "Failed to cache alias in Redis", err, "alias", opts.Alias)
+		}
+		return opts.Alias, nil
 	}
 
 	return shortLink, nil
 }
 
+// Inserts a batch of URLs into the database and returns a result per link, in
+// the same order. Invalid options and taken aliases only fail their own link.
+func (s *Service) CreateShortLinks(ctx context.Context, links []BatchLink) ([]BatchResult, error) {
+	if len(links) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	owner := ownerKeyID(ctx)
+	results := make([]BatchResult, len(links))
+	urls := make([]*model.URL, 0, len(links))
+	indexes := make([]int, 0, len(links))  // Position in links of each entry of urls.
+	deduped := make([]bool, 0, len(links)) // Whether each entry of urls is deduplicated.
+	firstByHash := make(map[string]int)    // Entry of urls of the first deduplicated link per hash.
+	sameAs := make(map[int]int)            // Position in links of a duplicate to the entry of urls it shares.
+	for i, link := range links {
+		if err := validateOptions(link.Options); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		hash := hashURL(link.LongURL)
+		if s.dedupe(link.Options) {
+			if j, ok := firstByHash[hash]; ok {
+				sameAs[i] = j
+				continue
+			}
+			id, err := s.findDuplicate(ctx, hash)
+			if err == nil {
+				results[i].ShortLink = s.codec.Encode(id)
+				continue
+			}
+			if !errors.Is(err, repository.ErrNotFound) {
+				return nil, err
+			}
+			firstByHash[hash] = len(urls)
+		}
+
+		urls = append(urls, &model.URL{LongURL: link.LongURL, Alias: link.Options.Alias, ExpiresAt: link.Options.ExpiresAt, LongURLHash: hash, OwnerKeyID: owner})
+		indexes = append(indexes, i)
+		deduped = append(deduped, s.dedupe(link.Options))
+	}
+	if len(urls) == 0 {
+		return results, nil
+	}
+
+	if err := s.assignIDs(ctx, urls...); err != nil {
+		return nil, err
+	}
+	ids, err := s.urlRepo.CreateShortLinks(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	for j, id := range ids {
+		result := &results[indexes[j]]
+		switch {
+		case id == 0:
+			result.Err = ErrAliasTaken
+		case urls[j].Alias != "":
+			result.ShortLink = urls[j].Alias
+		default:
+			result.ShortLink = s.codec.Encode(id)
+		}
+	}
+	for i, j := range sameAs {
+		results[i] = results[indexes[j]]
+	}
+
+	// Cache all new links in one round trip rather than one per link.
+	_, err = s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for j, id := range ids {
+			if id == 0 {
+				continue
+			}
+			ttl := s.cacheTTL(urls[j].ExpiresAt)
+			pipe.Set(ctx, REDIS_KEY_PREFIX+s.codec.Encode(id), urls[j].LongURL, ttl)
+			if urls[j].Alias != "" {
+				pipe.Set(ctx, REDIS_ALIAS_KEY_PREFIX+urls[j].Alias, id, ttl)
+			}
+			if deduped[j] {
+				pipe.Set(ctx, dedupeKey(owner, urls[j].LongURLHash), id, s.cacheTTL(nil))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		warnRedis(ctx, "Failed to cache short links in Redis", err, "count", len(ids))
+	}
+
+	return results, nil
+}
+
 // Retrieves the original URL from the short form and increment the access count.
-func (s *Service) GetLongURL(ctx context.Context, shortLink string) (string, error) {
-	id := base62.Decode(shortLink) // Get the DB ID from the short link.
+// Also returns the ID of the link, for RecordClick.
+func (s *Service) GetLongURL(ctx context.Context, shortLink string) (string, int64, error) {
+	// Get the DB ID from the short link.
+	id, err := s.resolveID(ctx, shortLink)
+	if err != nil {
+		return "", 0, err
+	}
+	if IsAlias(shortLink) {
+		shortLink = s.codec.Encode(id) // Aliases share the cache entry of the generated short link.
+	}
 
-	// Check if the long URL is present in Redis.
+	// Check if the long URL is present in Redis. Entries expire together with
+	// their link, so a hit is always still valid.
 	longURL, err := s.redisClient.Get(ctx, REDIS_KEY_PREFIX+shortLink).Result()
+	result := cacheResult(err)
+	metrics.CacheLookups.WithLabelValues(result).Inc()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("shortlink.cache", result))
 
 	// Fetch from database if not found.
 	if err != nil {
-		longURL, err = s.urlRepo.GetLongURL(ctx, id)
+		url, err := s.urlRepo.GetLongURL(ctx, id)
 		if err != nil {
-			return "", err
+			if errors.Is(err, repository.ErrNotFound) {
+				return "", 0, ErrShortLinkNotFound
+			}
+			return "", 0, err
 		}
-		// Cache the result in Redis for future requests.
-		err = s.redisClient.Set(ctx, "shortlink:"+shortLink, longURL, 0).Err()
-		if err != nil {
-			log.Printf("Failed to cache short link in Redis: %v", err)
+		if url.Expired() {
+			return "", 0, ErrShortLinkExpired
+		}
+		// Disabled links are never cached, so only the database knows about them.
+		if url.Disabled {
+			return "", 0, ErrShortLinkDisabled
 		}
+		longURL = url.LongURL
+		// Cache the result in Redis for future requests, unless Redis is down.
+		if result != "skipped" {
+			err = s.redisClient.Set(ctx, REDIS_KEY_PREFIX+shortLink, longURL, s.cacheTTL(url.ExpiresAt)).Err()
+			if err != nil {
+				warnRedis(ctx, "Failed to cache short link in Redis", err, "short_link", shortLink)
+			}
+		}
+	}
+
+	// Count the access. It is written to the database in a later batch.
+	if err := s.accessCounter.Add(ctx, id); err != nil {
+		logging.FromContext(ctx).Warn("Failed to record access", "id", id, "error", err)
+	}
+
+	return longURL, id, nil
+}
+
+// Changes the long URL of a short link if it is still at the given version, or
+// regardless when version is 0, and writes the new destination through to Redis.
+// Returns the updated link.
+func (s *Service) UpdateLongURL(ctx context.Context, shortLink, longURL string, version int64) (*model.URL, error) {
+	id, err := s.resolveID(ctx, shortLink)
+	if err != nil {
+		return nil, err
+	}
+	url, err := s.getOwnedLink(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	oldHash := url.LongURLHash
+	url.LongURL, url.LongURLHash, url.Version = longURL, hashURL(longURL), version
+	if err := s.urlRepo.UpdateLongURL(ctx, url); err != nil {
+		return nil, linkError(err)
 	}
 
-	// Increment the access count in the background.
-	go func(id int64) {
-		bgCtx := context.Background()
-		if err := s.urlRepo.IncrementAccessCount(bgCtx, id); err != nil {
-			log.Printf("Failed to increment access count for ID %d: %v", id, err)
+	// Stop deduplicating the old URL to this link.
+	if oldHash != "" && oldHash != url.LongURLHash {
+		if err := s.redisClient.Del(ctx, dedupeKey(url.OwnerKeyID, oldHash)).Err(); err != nil {
+			warnRedis(ctx, "Failed to evict long URL hash from Redis", err, "short_link", shortLink)
 		}
+	}
 
-	}(id)
+	// Links that don't redirect are never cached. If the new destination can't
+	// be cached, e.g. while Redis is skipped, the old one is still evicted.
+	key := REDIS_KEY_PREFIX + s.codec.Encode(id)
+	if url.Disabled || url.Expired() {
+		err = s.redisClient.Del(ctx, key).Err()
+	} else if err = s.redisClient.Set(ctx, key, longURL, s.cacheTTL(url.ExpiresAt)).Err(); err != nil {
+		err = s.redisClient.Del(ctx, key).Err()
+	}
+	if err != nil {
+		warnRedis(ctx, "Failed to update short link in Redis", err, "short_link", shortLink)
+	}
+	return url, nil
+}
+
+// Soft-deletes a short link and evicts it from Redis, so it stops redirecting right away.
+func (s *Service) DeleteLink(ctx context.Context, shortLink string) error {
+	return s.updateLink(ctx, shortLink, s.urlRepo.DeleteShortLink)
+}
+
+// Disables or re-enables a short link. Disabling evicts it from Redis, so it
+// stops redirecting right away.
+func (s *Service) SetLinkDisabled(ctx context.Context, shortLink string, disabled bool) error {
+	return s.updateLink(ctx, shortLink, func(ctx context.Context, id int64) error {
+		return s.urlRepo.SetDisabled(ctx, id, disabled)
+	})
+}
+
+// updateLink applies update to the link behind shortLink, then evicts every
+// Redis entry that could still lead to it.
+func (s *Service) updateLink(ctx context.Context, shortLink string, update func(ctx context.Context, id int64) error) error {
+	id, err := s.resolveID(ctx, shortLink)
+	if err != nil {
+		return err
+	}
+	url, err := s.getOwnedLink(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := update(ctx, id); err != nil {
+		return linkError(err)
+	}
 
-	return longURL, nil
+	keys := []string{REDIS_KEY_PREFIX + s.codec.Encode(id)}
+	if url.Alias != "" {
+		keys = append(keys, REDIS_ALIAS_KEY_PREFIX+url.Alias)
+	}
+	if url.LongURLHash != "" {
+		keys = append(keys, dedupeKey(url.OwnerKeyID, url.LongURLHash))
+	}
+	if err := s.redisClient.Del(ctx, keys...).Err(); err != nil {
+		warnRedis(ctx, "Failed to evict short link from Redis", err, "short_link", shortLink)
+	}
+	return nil
 }
 
 // Returns stats for a given short link.
 func (s *Service) GetLinkStats(ctx context.Context, shortLink string) (*model.URL, error) {
-	id := base62.Decode(shortLink)
-	return s.urlRepo.GetURLStats(ctx, id)
+	id, err := s.resolveID(ctx, shortLink)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.getOwnedLink(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Include accesses that haven't been synced to the database yet.
+	if counter, ok := s.accessCounter.(PendingCounter); ok {
+		pending, err := counter.Pending(ctx, id)
+		if err != nil {
+			warnRedis(ctx, "Failed to get pending access count", err, "id", id)
+		}
+		stats.AccessCount += pending
+	}
+	return stats, nil
+}
+
+// Returns the clicks of a short link per interval in loc, including empty
+// intervals. The range is widened to whole intervals, from the one containing
+// from to the one containing the instant before to.
+func (s *Service) GetClickTimeSeries(ctx context.Context, shortLink string, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	if !interval.Valid() || !from.Before(to) {
+		return nil, ErrInvalidRange
+	}
+
+	start := interval.Truncate(from, loc)
+	buckets := []model.ClickBucket{}
+	for t := start; t.Before(to); t = interval.Next(t) {
+		if len(buckets) == MaxTimeSeriesBuckets {
+			return nil, ErrInvalidRange
+		}
+		buckets = append(buckets, model.ClickBucket{Start: t})
+	}
+	end := interval.Next(buckets[len(buckets)-1].Start)
+
+	id, err := s.resolveID(ctx, shortLink)
+	if err != nil {
+		return nil, err
+	}
+	// Fail on unknown links rather than returning all zeros.
+	if _, err := s.getOwnedLink(ctx, id); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.clickRepo.CountClicks(ctx, id, start, end, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+	byStart := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		byStart[c.Start.Unix()] = c.Clicks
+	}
+	for i := range buckets {
+		buckets[i].Clicks = byStart[buckets[i].Start.Unix()]
+	}
+	return buckets, nil
+}
+
+// Records a click event for the link with the given ID, as returned by
+// GetLongURL, that was just redirected.
+func (s *Service) RecordClick(ctx context.Context, id int64, click model.Click, clientIP string) {
+	if s.clickRecorder == nil {
+		return
+	}
+	click.URLID = id
+	s.clickRecorder.Record(click, clientIP)
+}
+
+// resolveID maps a short link to its database ID. Generated short links are
+// decoded directly, while aliases are looked up in Redis and then the database.
+// Short links that can't exist, such as "favicon.ico", are not found without
+// a lookup.
+func (s *Service) resolveID(ctx context.Context, shortLink string) (int64, error) {
+	if !IsAlias(shortLink) {
+		id, ok := s.codec.Decode(shortLink)
+		if !ok {
+			return 0, ErrShortLinkNotFound
+		}
+		return id, nil
+	}
+	if !ValidAlias(shortLink) {
+		return 0, ErrShortLinkNotFound
+	}
+
+	cached, err := s.redisClient.Get(ctx, REDIS_ALIAS_KEY_PREFIX+shortLink).Result()
+	if err == nil {
+		if id, err := strconv.ParseInt(cached, 10, 64); err == nil {
+			return id, nil
+		}
+	}
+
+	id, err := s.urlRepo.GetIDByAlias(ctx, shortLink)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, ErrShortLinkNotFound
+		}
+		return 0, err
+	}
+
+	err = s.redisClient.Set(ctx, REDIS_ALIAS_KEY_PREFIX+shortLink, id, s.cacheTTL(nil)).Err()
+	if err != nil {
+		warnRedis(ctx, "Failed to cache alias in Redis", err, "alias", shortLink)
+	}
+	return id, nil
+}
+
+// assignIDs sets the IDs of new links if the service has an ID generator.
+func (s *Service) assignIDs(ctx context.Context, urls ...*model.URL) error {
+	if s.opts.IDGenerator == nil {
+		return nil
+	}
+	for _, url := range urls {
+		id, err := s.opts.IDGenerator.NextID(ctx)
+		if err != nil {
+			return err
+		}
+		url.ID = id
+	}
+	return nil
+}
+
+// getOwnedLink returns the link with the given ID if the API key of the request
+// may see it: when the request has no key or the key owns it. Links created
+// without a key, e.g. before authentication was enabled, belong to no key.
+// Links of other keys are reported as not found, so their existence isn't revealed.
+func (s *Service) getOwnedLink(ctx context.Context, id int64) (*model.URL, error) {
+	url, err := s.urlRepo.GetURLStats(ctx, id)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if owner := ownerKeyID(ctx); owner != 0 && url.OwnerKeyID != owner {
+		return nil, ErrShortLinkNotFound
+	}
+	return url, nil
+}
+
+// ownerKeyID returns the ID of the API key of the request, or 0 without one.
+func ownerKeyID(ctx context.Context) int64 {
+	if key, ok := auth.FromContext(ctx); ok {
+		return key.ID
+	}
+	return 0
+}
+
+// dedupeKey returns the Redis key mapping a long URL hash to the link that
+// new links of the owner reuse.
+func dedupeKey(ownerKeyID int64, hash string) string {
+	if ownerKeyID == 0 {
+		return REDIS_URL_KEY_PREFIX + hash
+	}
+	return REDIS_URL_KEY_PREFIX + strconv.FormatInt(ownerKeyID, 10) + ":" + hash
+}
+
+// warnRedis logs a failed Redis command. Commands skipped while Redis is down
+// would log on every request, so they are only counted by the circuit breaker.
+func warnRedis(ctx context.Context, msg string, err error, args ...any) {
+	if errors.Is(err, cache.ErrCircuitOpen) {
+		return
+	}
+	logging.FromContext(ctx).Warn(msg, append(args, "error", err)...)
+}
+
+// cacheResult labels the outcome of a cache lookup for metrics.CacheLookups
+// and the span of the request.
+// Without Redis every lookup is a miss rather than an error, and while Redis is
+// down lookups are skipped.
+func cacheResult(err error) string {
+	switch {
+	case err == nil:
+		return "hit"
+	case errors.Is(err, redis.Nil), errors.Is(err, cache.ErrRedisDisabled):
+		return "miss"
+	case errors.Is(err, cache.ErrCircuitOpen):
+		return "skipped"
+	default:
+		return "error"
+	}
+}
+
+// linkError maps a repository error about an existing link to a service error.
+func linkError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrShortLinkNotFound
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return ErrVersionMismatch
+	default:
+		return err
+	}
+}
+
+// dedupe reports whether a new link with the given options reuses an existing link.
+func (s *Service) dedupe(opts LinkOptions) bool {
+	if opts.Alias != "" || opts.ExpiresAt != nil {
+		return false
+	}
+	if opts.Dedupe != nil {
+		return *opts.Dedupe
+	}
+	return s.opts.Dedupe
+}
+
+// findDuplicate returns the ID of the link that new links to the URL with the
+// given hash reuse, looking in Redis and then the database.
+// Nothing stops a concurrent request from inserting the same URL between the
+// lookup and the insert, so duplicates are avoided on a best-effort basis.
+func (s *Service) findDuplicate(ctx context.Context, hash string) (int64, error) {
+	owner := ownerKeyID(ctx)
+	cached, err := s.redisClient.Get(ctx, dedupeKey(owner, hash)).Result()
+	if err == nil {
+		if id, err := strconv.ParseInt(cached, 10, 64); err == nil {
+			return id, nil
+		}
+	}
+
+	id, err := s.urlRepo.GetIDByLongURLHash(ctx, hash, owner)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.redisClient.Set(ctx, dedupeKey(owner, hash), id, s.cacheTTL(nil)).Err()
+	if err != nil {
+		warnRedis(ctx, "Failed to cache long URL hash in Redis", err, "id", id)
+	}
+	return id, nil
+}
+
+// validateOptions checks the options of a new link.
+func validateOptions(opts LinkOptions) error {
+	if opts.Alias != "" && !ValidAlias(opts.Alias) {
+		return ErrInvalidAlias
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()) {
+		return ErrInvalidExpiry
+	}
+	return nil
+}
+
+// cacheTTL returns the Redis expiration for a link expiring at expiresAt, or
+// for an entry not tied to a link's expiry when nil, where 0 means the entry is
+// kept until evicted.
+func (s *Service) cacheTTL(expiresAt *time.Time) time.Duration {
+	if expiresAt == nil {
+		return s.opts.CacheTTL
+	}
+	// Never pass 0 for a link that is about to expire, as that would cache it forever.
+	ttl := max(time.Until(*expiresAt), time.Millisecond)
+	if s.opts.CacheTTL > 0 {
+		ttl = min(ttl, s.opts.CacheTTL)
+	}
+	return ttl
 }
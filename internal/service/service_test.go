@@ -2,29 +2,63 @@ package service_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"shortlink-go/internal/auth"
+	"shortlink-go/internal/cache"
 	"shortlink-go/internal/model"
+	"shortlink-go/internal/repository"
 	"shortlink-go/internal/service"
+	"shortlink-go/internal/shortcode"
 	"shortlink-go/pkg/base62"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// urlHash returns the LongURLHash the service stores for longURL.
+func urlHash(longURL string) string {
+	sum := sha256.Sum256([]byte(service.NormalizeURL(longURL)))
+	return hex.EncodeToString(sum[:])
+}
+
 type MockURLRepository struct {
 	mock.Mock
 }
 
-func (m *MockURLRepository) CreateShortLink(ctx context.Context, longURL string) (int64, error) {
-	args := m.Called(ctx, longURL)
+func (m *MockURLRepository) CreateShortLink(ctx context.Context, url *model.URL) (int64, error) {
+	args := m.Called(ctx, url)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockURLRepository) GetLongURL(ctx context.Context, id int64) (string, error) {
+func (m *MockURLRepository) CreateShortLinks(ctx context.Context, urls []*model.URL) ([]int64, error) {
+	args := m.Called(ctx, urls)
+	if args.Get(0) != nil {
+		return args.Get(0).([]int64), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockURLRepository) GetLongURL(ctx context.Context, id int64) (*model.URL, error) {
 	args := m.Called(ctx, id)
-	return args.String(0), args.Error(1)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.URL), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockURLRepository) GetIDByAlias(ctx context.Context, alias string) (int64, error) {
+	args := m.Called(ctx, alias)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockURLRepository) GetIDByLongURLHash(ctx context.Context, hash string, ownerKeyID int64) (int64, error) {
+	args := m.Called(ctx, hash, ownerKeyID)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *MockURLRepository) GetURLStats(ctx context.Context, id int64) (*model.URL, error) {
@@ -35,11 +69,65 @@ func (m *MockURLRepository) GetURLStats(ctx context.Context, id int64) (*model.U
 	return nil, args.Error(1)
 }
 
+func (m *MockURLRepository) UpdateLongURL(ctx context.Context, url *model.URL) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
+}
+
+func (m *MockURLRepository) DeleteShortLink(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockURLRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	args := m.Called(ctx, id, disabled)
+	return args.Error(0)
+}
+
 func (m *MockURLRepository) IncrementAccessCount(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockURLRepository) IncrementAccessCounts(ctx context.Context, counts map[int64]int64) error {
+	args := m.Called(ctx, counts)
+	return args.Error(0)
+}
+
+type MockAccessCounter struct {
+	mock.Mock
+}
+
+func (m *MockAccessCounter) Add(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type MockClickRepository struct {
+	mock.Mock
+}
+
+func (m *MockClickRepository) RecordClicks(ctx context.Context, clicks []model.Click) error {
+	args := m.Called(ctx, clicks)
+	return args.Error(0)
+}
+
+func (m *MockClickRepository) CountClicks(ctx context.Context, urlID int64, from, to time.Time, interval model.Interval, loc *time.Location) ([]model.ClickBucket, error) {
+	args := m.Called(ctx, urlID, from, to, interval, loc)
+	if args.Get(0) != nil {
+		return args.Get(0).([]model.ClickBucket), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockClickRecorder struct {
+	mock.Mock
+}
+
+func (m *MockClickRecorder) Record(click model.Click, clientIP string) {
+	m.Called(click, clientIP)
+}
+
 type MockRedisClient struct {
 	mock.Mock
 }
@@ -57,20 +145,63 @@ func (m *MockRedisClient) Get(ctx context.Context, key string) *redis.StringCmd
 	return args.Get(0).(*redis.StringCmd)
 }
 
+func (m *MockRedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*redis.DurationCmd)
+}
+
+func (m *MockRedisClient) HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd {
+	args := m.Called(ctx, key, field, incr)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	args := m.Called(ctx, key, field)
+	return args.Get(0).(*redis.StringCmd)
+}
+
+func (m *MockRedisClient) Eval(ctx context.Context, script string, keys []string, a ...interface{}) *redis.Cmd {
+	args := m.Called(ctx, script, keys, a)
+	return args.Get(0).(*redis.Cmd)
+}
+
+func (m *MockRedisClient) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	args := m.Called(ctx, fn)
+	if cmds := args.Get(0); cmds != nil {
+		return cmds.([]redis.Cmder), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockPendingCounter struct {
+	MockAccessCounter
+}
+
+func (m *MockPendingCounter) Pending(ctx context.Context, id int64) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestService_CreateShortLink(t *testing.T) {
 	mockURLRepo := new(MockURLRepository)
 	mockRedisClient := new(MockRedisClient)
-	svc := service.NewService(mockURLRepo, mockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
 
 	ctx := context.Background()
 	longURL := "http://example.com"
 	mockID := int64(1)
 	expectedShortLink := base62.Encode(mockID)
 
-	mockURLRepo.On("CreateShortLink", ctx, longURL).Return(mockID, nil)
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: longURL, LongURLHash: urlHash(longURL)}).Return(mockID, nil)
 	mockRedisClient.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&redis.StatusCmd{})
 
-	shortLink, err := svc.CreateShortLink(ctx, longURL)
+	shortLink, err := svc.CreateShortLink(ctx, longURL, service.LinkOptions{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedShortLink, shortLink)
@@ -78,40 +209,269 @@ func TestService_CreateShortLink(t *testing.T) {
 	mockRedisClient.AssertExpectations(t)
 }
 
+func TestService_CreateShortLink_Alias(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	longURL := "http://example.com"
+	alias := "spring-sale"
+	mockID := int64(7)
+
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: longURL, Alias: alias, LongURLHash: urlHash(longURL)}).Return(mockID, nil)
+	mockRedisClient.On("Set", ctx, service.REDIS_KEY_PREFIX+base62.Encode(mockID), longURL, time.Duration(0)).Return(&redis.StatusCmd{})
+	mockRedisClient.On("Set", ctx, service.REDIS_ALIAS_KEY_PREFIX+alias, mockID, time.Duration(0)).Return(&redis.StatusCmd{})
+
+	shortLink, err := svc.CreateShortLink(ctx, longURL, service.LinkOptions{Alias: alias})
+
+	assert.NoError(t, err)
+	assert.Equal(t, alias, shortLink)
+	mockURLRepo.AssertExpectations(t)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_CreateShortLink_InvalidAlias(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	for _, alias := range []string{"ab", "abc123", "has space", "emoji-\u2603"} {
+		_, err := svc.CreateShortLink(context.Background(), "http://example.com", service.LinkOptions{Alias: alias})
+		assert.ErrorIs(t, err, service.ErrInvalidAlias, alias)
+	}
+	mockURLRepo.AssertNotCalled(t, "CreateShortLink", mock.Anything, mock.Anything)
+}
+
+func TestService_CreateShortLink_AliasTaken(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	mockURLRepo.On("CreateShortLink", ctx, mock.Anything).Return(int64(0), repository.ErrAliasTaken)
+
+	_, err := svc.CreateShortLink(ctx, "http://example.com", service.LinkOptions{Alias: "spring-sale"})
+
+	assert.ErrorIs(t, err, service.ErrAliasTaken)
+}
+
+func TestService_CreateShortLinks(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	svc := service.NewService(mockURLRepo, nil, redisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	links := []service.BatchLink{
+		{LongURL: "http://example.com/a"},
+		{LongURL: "http://example.com/b", Options: service.LinkOptions{Alias: "ab"}},
+		{LongURL: "http://example.com/c", Options: service.LinkOptions{Alias: "spring-sale"}},
+		{LongURL: "http://example.com/d", Options: service.LinkOptions{Alias: "taken-alias"}},
+	}
+	mockURLRepo.On("CreateShortLinks", ctx, []*model.URL{
+		{LongURL: "http://example.com/a", LongURLHash: urlHash("http://example.com/a")},
+		{LongURL: "http://example.com/c", Alias: "spring-sale", LongURLHash: urlHash("http://example.com/c")},
+		{LongURL: "http://example.com/d", Alias: "taken-alias", LongURLHash: urlHash("http://example.com/d")},
+	}).Return([]int64{1, 2, 0}, nil)
+
+	results, err := svc.CreateShortLinks(ctx, links)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []service.BatchResult{
+		{ShortLink: base62.Encode(1)},
+		{Err: service.ErrInvalidAlias},
+		{ShortLink: "spring-sale"},
+		{Err: service.ErrAliasTaken},
+	}, results)
+	mockURLRepo.AssertExpectations(t)
+
+	// The created links are cached in a single pipeline.
+	cached, _ := server.Get(service.REDIS_KEY_PREFIX + base62.Encode(1))
+	assert.Equal(t, "http://example.com/a", cached)
+	cached, _ = server.Get(service.REDIS_ALIAS_KEY_PREFIX + "spring-sale")
+	assert.Equal(t, "2", cached)
+	assert.False(t, server.Exists(service.REDIS_ALIAS_KEY_PREFIX+"taken-alias"))
+}
+
+func TestService_CreateShortLinks_TooLarge(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	_, err := svc.CreateShortLinks(context.Background(), make([]service.BatchLink, service.MaxBatchSize+1))
+
+	assert.ErrorIs(t, err, service.ErrBatchTooLarge)
+	mockURLRepo.AssertNotCalled(t, "CreateShortLinks", mock.Anything, mock.Anything)
+}
+
+func TestService_CreateShortLink_Dedupe(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	svc := service.NewService(mockURLRepo, nil, redisClient, nil, nil, service.Options{Dedupe: true})
+
+	ctx := context.Background()
+	hash := urlHash("https://example.com/")
+	mockURLRepo.On("GetIDByLongURLHash", ctx, hash, int64(0)).Return(int64(0), repository.ErrNotFound).Once()
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: "https://example.com", LongURLHash: hash}).Return(int64(5), nil).Once()
+
+	shortLink, err := svc.CreateShortLink(ctx, "https://example.com", service.LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(5), shortLink)
+
+	// A differently spelled copy is served from the reverse cache entry.
+	shortLink, err = svc.CreateShortLink(ctx, "HTTPS://Example.com:443/", service.LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(5), shortLink)
+	mockURLRepo.AssertExpectations(t)
+
+	// Opting out creates a new link.
+	noDedupe := false
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: "https://example.com", LongURLHash: hash}).Return(int64(6), nil).Once()
+	shortLink, err = svc.CreateShortLink(ctx, "https://example.com", service.LinkOptions{Dedupe: &noDedupe})
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(6), shortLink)
+}
+
+func TestService_CreateShortLinks_Dedupe(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, cache.NopClient{}, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	dedupe := true
+	links := []service.BatchLink{
+		{LongURL: "http://example.com/known", Options: service.LinkOptions{Dedupe: &dedupe}},
+		{LongURL: "http://example.com/new", Options: service.LinkOptions{Dedupe: &dedupe}},
+		{LongURL: "http://EXAMPLE.com/new", Options: service.LinkOptions{Dedupe: &dedupe}},
+		{LongURL: "http://example.com/new"},
+	}
+	mockURLRepo.On("GetIDByLongURLHash", ctx, urlHash("http://example.com/known"), int64(0)).Return(int64(3), nil)
+	mockURLRepo.On("GetIDByLongURLHash", ctx, urlHash("http://example.com/new"), int64(0)).Return(int64(0), repository.ErrNotFound)
+	mockURLRepo.On("CreateShortLinks", ctx, []*model.URL{
+		{LongURL: "http://example.com/new", LongURLHash: urlHash("http://example.com/new")},
+		{LongURL: "http://example.com/new", LongURLHash: urlHash("http://example.com/new")},
+	}).Return([]int64{10, 11}, nil)
+
+	results, err := svc.CreateShortLinks(ctx, links)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []service.BatchResult{
+		{ShortLink: base62.Encode(3)},
+		{ShortLink: base62.Encode(10)},
+		{ShortLink: base62.Encode(10)},
+		{ShortLink: base62.Encode(11)},
+	}, results)
+	mockURLRepo.AssertExpectations(t)
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"HTTPS://Example.COM":              "https://example.com/",
+		"http://example.com:80/a?B=1#Frag": "http://example.com/a?B=1#Frag",
+		"https://example.com:8443/Path":    "https://example.com:8443/Path",
+		"https://[2001:DB8::1]:443/":       "https://[2001:db8::1]/",
+		"mailto:someone@example.com":       "mailto:someone@example.com",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, service.NormalizeURL(in), in)
+	}
+}
+
+func TestService_GetLongURL_Alias(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	alias := "spring-sale"
+	id := int64(7)
+	expectedLongURL := "http://example.com"
+	mockRedisClient.On("Get", ctx, service.REDIS_ALIAS_KEY_PREFIX+alias).Return(redis.NewStringResult("", redis.Nil))
+	mockURLRepo.On("GetIDByAlias", ctx, alias).Return(id, nil)
+	mockRedisClient.On("Set", ctx, service.REDIS_ALIAS_KEY_PREFIX+alias, id, time.Duration(0)).Return(&redis.StatusCmd{})
+	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+base62.Encode(id)).Return(redis.NewStringResult(expectedLongURL, nil))
+	mockCounter.On("Add", mock.Anything, id).Return(nil)
+
+	longURL, gotID, err := svc.GetLongURL(ctx, alias)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedLongURL, longURL)
+	assert.Equal(t, id, gotID)
+	mockRedisClient.AssertExpectations(t)
+	mockURLRepo.AssertCalled(t, "GetIDByAlias", ctx, alias)
+}
+
+func TestService_GetLongURL_UnknownAlias(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	alias := "no-such-alias"
+	mockRedisClient.On("Get", ctx, service.REDIS_ALIAS_KEY_PREFIX+alias).Return(redis.NewStringResult("", redis.Nil))
+	mockURLRepo.On("GetIDByAlias", ctx, alias).Return(int64(0), repository.ErrNotFound)
+
+	_, _, err := svc.GetLongURL(ctx, alias)
+
+	assert.ErrorIs(t, err, service.ErrShortLinkNotFound)
+}
+
+func TestService_GetLongURL_InvalidShortLink(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	// Other characters, leading zeros, int64 overflow and invalid aliases.
+	for _, shortLink := range []string{"favicon.ico", "0abc", "zzzzzzzzzzz", "robots.txt-", "a/b_c"} {
+		_, _, err := svc.GetLongURL(ctx, shortLink)
+		assert.ErrorIs(t, err, service.ErrShortLinkNotFound, shortLink)
+		_, err = svc.GetLinkStats(ctx, shortLink)
+		assert.ErrorIs(t, err, service.ErrShortLinkNotFound, shortLink)
+	}
+	mockRedisClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	mockURLRepo.AssertNotCalled(t, "GetLongURL", mock.Anything, mock.Anything)
+	mockURLRepo.AssertNotCalled(t, "GetURLStats", mock.Anything, mock.Anything)
+}
+
 func TestService_GetLongURL_RedisHit(t *testing.T) {
 	mockURLRepo := new(MockURLRepository)
 	mockRedisClient := new(MockRedisClient)
-	svc := service.NewService(mockURLRepo, mockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
 
 	ctx := context.Background()
 	shortLink := "abc123"
 	expectedLongURL := "http://example.com"
 	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+shortLink).Return(redis.NewStringResult(expectedLongURL, nil))
-	mockURLRepo.On("IncrementAccessCount", mock.Anything, mock.Anything).Return(nil)
+	mockCounter.On("Add", mock.Anything, mock.Anything).Return(nil)
 
-	longURL, err := svc.GetLongURL(ctx, shortLink)
+	longURL, _, err := svc.GetLongURL(ctx, shortLink)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedLongURL, longURL)
 	mockRedisClient.AssertExpectations(t)
 	mockURLRepo.AssertNotCalled(t, "GetLongURL", mock.Anything, mock.Anything)
+	mockCounter.AssertCalled(t, "Add", ctx, base62.Decode(shortLink))
 }
 
 func TestService_GetLongURL_RedisMiss_DBHit(t *testing.T) {
 	mockURLRepo := new(MockURLRepository)
 	mockRedisClient := new(MockRedisClient)
-	svc := service.NewService(mockURLRepo, mockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
 
 	ctx := context.Background()
 	shortLink := "abc123"
 	expectedID := base62.Decode(shortLink)
 	expectedLongURL := "http://example.com"
 	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+shortLink).Return(redis.NewStringResult("", redis.Nil))
-	mockURLRepo.On("GetLongURL", ctx, expectedID).Return(expectedLongURL, nil)
+	mockURLRepo.On("GetLongURL", ctx, expectedID).Return(&model.URL{ID: expectedID, LongURL: expectedLongURL}, nil)
 	mockRedisClient.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&redis.StatusCmd{})
-	mockURLRepo.On("IncrementAccessCount", mock.Anything, mock.Anything).Return(nil)
+	mockCounter.On("Add", mock.Anything, mock.Anything).Return(nil)
 
-	longURL, err := svc.GetLongURL(ctx, shortLink)
+	longURL, _, err := svc.GetLongURL(ctx, shortLink)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedLongURL, longURL)
@@ -119,9 +479,238 @@ func TestService_GetLongURL_RedisMiss_DBHit(t *testing.T) {
 	mockURLRepo.AssertCalled(t, "GetLongURL", mock.Anything, expectedID)
 }
 
+func TestService_GetLongURL_CircuitOpen(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := "abc123"
+	expectedID := base62.Decode(shortLink)
+	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+shortLink).Return(redis.NewStringResult("", cache.ErrCircuitOpen))
+	mockURLRepo.On("GetLongURL", ctx, expectedID).Return(&model.URL{ID: expectedID, LongURL: "http://example.com"}, nil)
+	mockCounter.On("Add", mock.Anything, mock.Anything).Return(nil)
+
+	longURL, _, err := svc.GetLongURL(ctx, shortLink)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com", longURL)
+	// Redis is down, so caching the result isn't even tried.
+	mockRedisClient.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_CreateShortLink_Expiry(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	longURL := "http://example.com"
+	expiresAt := time.Now().Add(time.Hour)
+
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: longURL, ExpiresAt: &expiresAt, LongURLHash: urlHash(longURL)}).Return(int64(1), nil)
+	mockRedisClient.On("Set", ctx, service.REDIS_KEY_PREFIX+base62.Encode(1), longURL, mock.MatchedBy(func(ttl time.Duration) bool {
+		return ttl > 59*time.Minute && ttl <= time.Hour
+	})).Return(&redis.StatusCmd{})
+
+	_, err := svc.CreateShortLink(ctx, longURL, service.LinkOptions{ExpiresAt: &expiresAt})
+
+	assert.NoError(t, err)
+	mockURLRepo.AssertExpectations(t)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_CreateShortLink_PastExpiry(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err := svc.CreateShortLink(context.Background(), "http://example.com", service.LinkOptions{ExpiresAt: &expiresAt})
+
+	assert.ErrorIs(t, err, service.ErrInvalidExpiry)
+	mockURLRepo.AssertNotCalled(t, "CreateShortLink", mock.Anything, mock.Anything)
+}
+
+func TestService_GetLongURL_Expired(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	mockCounter := new(MockAccessCounter)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := "abc123"
+	id := base62.Decode(shortLink)
+	expiredAt := time.Now().Add(-time.Minute)
+	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+shortLink).Return(redis.NewStringResult("", redis.Nil))
+	mockURLRepo.On("GetLongURL", ctx, id).Return(&model.URL{ID: id, LongURL: "http://example.com", ExpiresAt: &expiredAt}, nil)
+
+	_, _, err := svc.GetLongURL(ctx, shortLink)
+
+	assert.ErrorIs(t, err, service.ErrShortLinkExpired)
+	mockRedisClient.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockCounter.AssertNotCalled(t, "Add", mock.Anything, mock.Anything)
+}
+
+func TestService_UpdateLongURL(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := base62.Encode(3)
+	oldHash := urlHash("http://example.com/old")
+	mockURLRepo.On("GetURLStats", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/old", LongURLHash: oldHash, Version: 2}, nil)
+	mockURLRepo.On("UpdateLongURL", ctx, mock.MatchedBy(func(url *model.URL) bool {
+		return url.ID == 3 && url.LongURL == "http://example.com/new" && url.LongURLHash == urlHash("http://example.com/new") && url.Version == 2
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.URL).Version = 3
+	}).Return(nil)
+	mockRedisClient.On("Del", ctx, []string{service.REDIS_URL_KEY_PREFIX + oldHash}).Return(redis.NewIntResult(1, nil))
+	mockRedisClient.On("Set", ctx, service.REDIS_KEY_PREFIX+shortLink, "http://example.com/new", time.Duration(0)).Return(redis.NewStatusResult("OK", nil))
+
+	url, err := svc.UpdateLongURL(ctx, shortLink, "http://example.com/new", 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), url.Version)
+	mockURLRepo.AssertExpectations(t)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_UpdateLongURL_EvictsWhenNotCached(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := base62.Encode(3)
+	mockURLRepo.On("GetURLStats", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/old"}, nil)
+	mockURLRepo.On("UpdateLongURL", ctx, mock.Anything).Return(nil)
+	mockRedisClient.On("Set", ctx, service.REDIS_KEY_PREFIX+shortLink, "http://example.com/new", time.Duration(0)).Return(redis.NewStatusResult("", cache.ErrCircuitOpen))
+	mockRedisClient.On("Del", ctx, []string{service.REDIS_KEY_PREFIX + shortLink}).Return(redis.NewIntResult(1, nil))
+
+	_, err := svc.UpdateLongURL(ctx, shortLink, "http://example.com/new", 0)
+
+	assert.NoError(t, err)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_UpdateLongURL_DuringOutage(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockCounter := new(MockAccessCounter)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	breaker := cache.NewBreaker(client, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}, cache.BreakerOptions{Threshold: 2, ProbeInterval: 10 * time.Millisecond})
+	t.Cleanup(func() { breaker.Close() })
+	svc := service.NewService(mockURLRepo, nil, breaker, mockCounter, nil, service.Options{CacheTTL: time.Hour})
+
+	ctx := context.Background()
+	shortLink := base62.Encode(3)
+	key := service.REDIS_KEY_PREFIX + shortLink
+	mockURLRepo.On("GetLongURL", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/old"}, nil).Once()
+	mockCounter.On("Add", ctx, int64(3)).Return(nil)
+	_, _, err := svc.GetLongURL(ctx, shortLink)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, server.TTL(key))
+
+	// Neither the new destination nor the eviction reaches Redis.
+	server.Close()
+	mockURLRepo.On("GetURLStats", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/old"}, nil)
+	mockURLRepo.On("UpdateLongURL", ctx, mock.Anything).Return(nil)
+	_, err = svc.UpdateLongURL(ctx, shortLink, "http://example.com/new", 0)
+	assert.NoError(t, err)
+	assert.True(t, breaker.Open())
+
+	assert.NoError(t, server.Restart())
+	assert.Eventually(t, func() bool { return !breaker.Open() }, time.Second, 10*time.Millisecond)
+	longURL, _, err := svc.GetLongURL(ctx, shortLink)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/old", longURL)
+
+	// The stale entry expires instead of being served forever.
+	server.FastForward(time.Hour)
+	mockURLRepo.On("GetLongURL", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/new"}, nil).Once()
+	longURL, _, err = svc.GetLongURL(ctx, shortLink)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/new", longURL)
+	mockURLRepo.AssertExpectations(t)
+}
+
+func TestService_UpdateLongURL_VersionMismatch(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	mockURLRepo.On("GetURLStats", ctx, int64(3)).Return(&model.URL{ID: 3, LongURL: "http://example.com/old", Version: 5}, nil)
+	mockURLRepo.On("UpdateLongURL", ctx, mock.Anything).Return(repository.ErrVersionMismatch)
+
+	_, err := svc.UpdateLongURL(ctx, base62.Encode(3), "http://example.com/new", 4)
+
+	assert.ErrorIs(t, err, service.ErrVersionMismatch)
+	mockRedisClient.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_DeleteLink(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	alias := "spring-sale"
+	mockID := int64(7)
+	mockRedisClient.On("Get", ctx, service.REDIS_ALIAS_KEY_PREFIX+alias).Return(redis.NewStringResult("7", nil))
+	mockURLRepo.On("GetURLStats", ctx, mockID).Return(&model.URL{ID: mockID, Alias: alias, LongURLHash: "h"}, nil)
+	mockURLRepo.On("DeleteShortLink", ctx, mockID).Return(nil)
+	mockRedisClient.On("Del", ctx, []string{
+		service.REDIS_KEY_PREFIX + base62.Encode(mockID),
+		service.REDIS_ALIAS_KEY_PREFIX + alias,
+		service.REDIS_URL_KEY_PREFIX + "h",
+	}).Return(redis.NewIntResult(3, nil))
+
+	err := svc.DeleteLink(ctx, alias)
+
+	assert.NoError(t, err)
+	mockURLRepo.AssertExpectations(t)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_SetLinkDisabled_NotFound(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	mockURLRepo.On("GetURLStats", ctx, int64(1)).Return(nil, repository.ErrNotFound)
+
+	err := svc.SetLinkDisabled(ctx, base62.Encode(1), true)
+
+	assert.ErrorIs(t, err, service.ErrShortLinkNotFound)
+	mockURLRepo.AssertNotCalled(t, "SetDisabled", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_GetLongURL_Disabled(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := base62.Encode(1)
+	mockRedisClient.On("Get", ctx, service.REDIS_KEY_PREFIX+shortLink).Return(redis.NewStringResult("", redis.Nil))
+	mockURLRepo.On("GetLongURL", ctx, int64(1)).Return(&model.URL{ID: 1, LongURL: "http://example.com", Disabled: true}, nil)
+
+	_, _, err := svc.GetLongURL(ctx, shortLink)
+
+	assert.ErrorIs(t, err, service.ErrShortLinkDisabled)
+	mockRedisClient.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestService_GetLinkStats_Success(t *testing.T) {
 	mockURLRepo := new(MockURLRepository)
-	svc := service.NewService(mockURLRepo, nil) // For this test, Redis interaction is not involved
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{}) // For this test, Redis interaction is not involved
 
 	ctx := context.Background()
 	shortLink := "abc123"
@@ -140,3 +729,183 @@ func TestService_GetLinkStats_Success(t *testing.T) {
 	assert.Equal(t, expectedStats, stats)
 	mockURLRepo.AssertExpectations(t)
 }
+
+func TestService_ObfuscatedShortLinks(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	codec := shortcode.NewObfuscated("secret")
+	svc := service.NewService(mockURLRepo, nil, redisClient, nil, nil, service.Options{Codec: codec})
+
+	ctx := context.Background()
+	mockURLRepo.On("CreateShortLink", ctx, mock.Anything).Return(int64(1), nil)
+	mockURLRepo.On("GetURLStats", ctx, int64(1)).Return(&model.URL{ID: 1, LongURL: "https://example.com"}, nil)
+
+	shortLink, err := svc.CreateShortLink(ctx, "https://example.com", service.LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, codec.Encode(1), shortLink)
+	assert.NotEqual(t, base62.Encode(1), shortLink)
+
+	// The short link maps back to the ID without a lookup.
+	stats, err := svc.GetLinkStats(ctx, shortLink)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.ID)
+}
+
+type fixedIDGenerator struct{ next int64 }
+
+func (g *fixedIDGenerator) NextID(ctx context.Context) (int64, error) {
+	g.next++
+	return g.next, nil
+}
+
+func TestService_IDGenerator(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	svc := service.NewService(mockURLRepo, nil, redisClient, nil, nil, service.Options{IDGenerator: &fixedIDGenerator{next: 41}})
+
+	ctx := context.Background()
+	mockURLRepo.On("CreateShortLink", ctx, mock.MatchedBy(func(url *model.URL) bool { return url.ID == 42 })).Return(int64(42), nil)
+	mockURLRepo.On("CreateShortLinks", ctx, mock.MatchedBy(func(urls []*model.URL) bool {
+		return len(urls) == 2 && urls[0].ID == 43 && urls[1].ID == 44
+	})).Return([]int64{43, 44}, nil)
+
+	shortLink, err := svc.CreateShortLink(ctx, "https://example.com", service.LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(42), shortLink)
+
+	results, err := svc.CreateShortLinks(ctx, []service.BatchLink{{LongURL: "https://example.com/a"}, {LongURL: "https://example.com/b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(43), results[0].ShortLink)
+	assert.Equal(t, base62.Encode(44), results[1].ShortLink)
+	mockURLRepo.AssertExpectations(t)
+}
+
+func TestService_GetLinkStats_OtherOwner(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	ctx := auth.NewContext(context.Background(), &model.APIKey{ID: 2})
+	mockURLRepo.On("GetURLStats", ctx, int64(1)).Return(&model.URL{ID: 1, OwnerKeyID: 3}, nil)
+	mockURLRepo.On("GetURLStats", ctx, int64(2)).Return(&model.URL{ID: 2}, nil)
+
+	// Links of other keys are hidden, and so are links without an owner.
+	_, err := svc.GetLinkStats(ctx, base62.Encode(1))
+	assert.ErrorIs(t, err, service.ErrShortLinkNotFound)
+	_, err = svc.GetLinkStats(ctx, base62.Encode(2))
+	assert.ErrorIs(t, err, service.ErrShortLinkNotFound)
+
+	// Without a key, e.g. with authentication disabled, every link is visible.
+	mockURLRepo.On("GetURLStats", context.Background(), int64(2)).Return(&model.URL{ID: 2}, nil)
+	_, err = svc.GetLinkStats(context.Background(), base62.Encode(2))
+	assert.NoError(t, err)
+}
+
+func TestService_UpdateLinks_Unowned(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	svc := service.NewService(mockURLRepo, nil, nil, nil, nil, service.Options{})
+
+	ctx := auth.NewContext(context.Background(), &model.APIKey{ID: 2})
+	mockURLRepo.On("GetURLStats", ctx, int64(2)).Return(&model.URL{ID: 2, LongURL: "http://example.com"}, nil)
+
+	_, err := svc.UpdateLongURL(ctx, base62.Encode(2), "http://example.com/phishing", 0)
+	assert.ErrorIs(t, err, service.ErrShortLinkNotFound)
+	assert.ErrorIs(t, svc.SetLinkDisabled(ctx, base62.Encode(2), true), service.ErrShortLinkNotFound)
+	assert.ErrorIs(t, svc.DeleteLink(ctx, base62.Encode(2)), service.ErrShortLinkNotFound)
+	mockURLRepo.AssertNotCalled(t, "UpdateLongURL", mock.Anything, mock.Anything)
+	mockURLRepo.AssertNotCalled(t, "SetDisabled", mock.Anything, mock.Anything, mock.Anything)
+	mockURLRepo.AssertNotCalled(t, "DeleteShortLink", mock.Anything, mock.Anything)
+}
+
+func TestService_CreateShortLink_Owner(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockRedisClient := new(MockRedisClient)
+	svc := service.NewService(mockURLRepo, nil, mockRedisClient, nil, nil, service.Options{Dedupe: true})
+
+	ctx := auth.NewContext(context.Background(), &model.APIKey{ID: 4})
+	hash := urlHash("https://example.com/")
+	dedupeKey := service.REDIS_URL_KEY_PREFIX + "4:" + hash
+	mockRedisClient.On("Get", ctx, dedupeKey).Return(redis.NewStringResult("", redis.Nil))
+	mockURLRepo.On("GetIDByLongURLHash", ctx, hash, int64(4)).Return(int64(0), repository.ErrNotFound)
+	mockURLRepo.On("CreateShortLink", ctx, &model.URL{LongURL: "https://example.com", LongURLHash: hash, OwnerKeyID: 4}).Return(int64(5), nil)
+	mockRedisClient.On("Set", ctx, service.REDIS_KEY_PREFIX+base62.Encode(5), "https://example.com", time.Duration(0)).Return(redis.NewStatusResult("OK", nil))
+	mockRedisClient.On("Set", ctx, dedupeKey, int64(5), time.Duration(0)).Return(redis.NewStatusResult("OK", nil))
+
+	shortLink, err := svc.CreateShortLink(ctx, "https://example.com", service.LinkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, base62.Encode(5), shortLink)
+	mockURLRepo.AssertExpectations(t)
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestService_GetLinkStats_PendingAccesses(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockCounter := new(MockPendingCounter)
+	svc := service.NewService(mockURLRepo, nil, nil, mockCounter, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := "abc123"
+	id := base62.Decode(shortLink)
+	mockURLRepo.On("GetURLStats", ctx, id).Return(&model.URL{ID: id, LongURL: "http://example.com", AccessCount: 40}, nil)
+	mockCounter.On("Pending", ctx, id).Return(int64(2), nil)
+
+	stats, err := svc.GetLinkStats(ctx, shortLink)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), stats.AccessCount)
+	mockCounter.AssertExpectations(t)
+}
+
+func TestService_RecordClick(t *testing.T) {
+	mockRecorder := new(MockClickRecorder)
+	svc := service.NewService(nil, nil, nil, nil, mockRecorder, service.Options{})
+
+	click := model.Click{ClickedAt: time.Now(), Referrer: "https://news.example.com"}
+	expected := click
+	expected.URLID = 42
+	mockRecorder.On("Record", expected, "203.0.113.7").Return()
+
+	svc.RecordClick(context.Background(), 42, click, "203.0.113.7")
+
+	mockRecorder.AssertExpectations(t)
+}
+
+func TestService_GetClickTimeSeries(t *testing.T) {
+	mockURLRepo := new(MockURLRepository)
+	mockClickRepo := new(MockClickRepository)
+	svc := service.NewService(mockURLRepo, mockClickRepo, nil, nil, nil, service.Options{})
+
+	ctx := context.Background()
+	shortLink := "abc123"
+	id := base62.Decode(shortLink)
+	loc, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, loc)
+	to := time.Date(2024, 3, 4, 0, 0, 0, 0, loc)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, loc) }
+
+	mockURLRepo.On("GetURLStats", ctx, id).Return(&model.URL{ID: id}, nil)
+	mockClickRepo.On("CountClicks", ctx, id, day(1), day(4), model.Day, loc).
+		Return([]model.ClickBucket{{Start: day(2).UTC(), Clicks: 5}}, nil)
+
+	buckets, err := svc.GetClickTimeSeries(ctx, shortLink, from, to, model.Day, loc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClickBucket{
+		{Start: day(1), Clicks: 0},
+		{Start: day(2), Clicks: 5},
+		{Start: day(3), Clicks: 0},
+	}, buckets)
+}
+
+func TestService_GetClickTimeSeries_InvalidRange(t *testing.T) {
+	svc := service.NewService(nil, nil, nil, nil, nil, service.Options{})
+	now := time.Now()
+
+	_, err := svc.GetClickTimeSeries(context.Background(), "abc", now, now.Add(-time.Hour), model.Hour, time.UTC)
+	assert.ErrorIs(t, err, service.ErrInvalidRange)
+
+	_, err = svc.GetClickTimeSeries(context.Background(), "abc", now.AddDate(-2, 0, 0), now, model.Hour, time.UTC)
+	assert.ErrorIs(t, err, service.ErrInvalidRange)
+}
@@ -0,0 +1,138 @@
+package shortcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"shortlink-go/pkg/base62"
+)
+
+// MaxLength is the length of the longest short link, that of math.MaxInt64.
+const MaxLength = 11
+
+// Codec turns link IDs into short links and back.
+type Codec interface {
+	Encode(id int64) string
+	// Decode returns the ID of a short link, or false if no ID is encoded as
+	// shortLink.
+	Decode(shortLink string) (int64, bool)
+}
+
+// Sequential encodes IDs as they are, so consecutive links get consecutive
+// short links.
+type Sequential struct{}
+
+func (Sequential) Encode(id int64) string {
+	return base62.Encode(id)
+}
+
+func (Sequential) Decode(shortLink string) (int64, bool) {
+	return decode(shortLink)
+}
+
+// decode returns the ID encoded as shortLink, or false if shortLink isn't the
+// base62 encoding of a positive int64. This rules out other characters, codes
+// that overflow an int64 and leading zeros, which would make several codes
+// decode to the same ID.
+func decode(shortLink string) (int64, bool) {
+	if shortLink == "" || len(shortLink) > MaxLength {
+		return 0, false
+	}
+	for i := 0; i < len(shortLink); i++ {
+		switch c := shortLink[i]; {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		default:
+			return 0, false
+		}
+	}
+	// Overflowing codes wrap around and leading zeros are dropped, so neither
+	// encodes back to the same code.
+	id := base62.Decode(shortLink)
+	if id <= 0 || base62.Encode(id) != shortLink {
+		return 0, false
+	}
+	return id, true
+}
+
+const (
+	// halfBits is the size of each half of the Feistel block. IDs below
+	// 1<<(2*halfBits) are permuted, which keeps short links at 9 characters or
+	// fewer. Larger IDs are left as they are.
+	halfBits = 24
+	halfMask = 1<<halfBits - 1
+	maxID    = 1 << (2 * halfBits)
+	rounds   = 4
+)
+
+// Obfuscated permutes IDs with a Feistel network keyed with a secret before
+// encoding them, so short links can't be guessed from one another. Decoding
+// reverses the permutation, so no lookup is needed.
+type Obfuscated struct {
+	key []byte
+}
+
+func NewObfuscated(secret string) *Obfuscated {
+	return &Obfuscated{key: []byte(secret)}
+}
+
+func (o *Obfuscated) Encode(id int64) string {
+	return base62.Encode(o.Permute(id))
+}
+
+func (o *Obfuscated) Decode(shortLink string) (int64, bool) {
+	id, ok := decode(shortLink)
+	if !ok {
+		return 0, false
+	}
+	return o.Unpermute(id), true
+}
+
+// Permute maps an ID to another one, one to one. IDs outside of [1, 1<<48)
+// are returned as they are. 0 is skipped, so no ID is permuted to it.
+func (o *Obfuscated) Permute(id int64) int64 {
+	if id <= 0 || id >= maxID {
+		return id
+	}
+	// Cycle-walk past 0, which keeps the permutation within [1, 1<<48).
+	id = o.feistel(id, false)
+	for id == 0 {
+		id = o.feistel(id, false)
+	}
+	return id
+}
+
+// Unpermute reverses Permute.
+func (o *Obfuscated) Unpermute(id int64) int64 {
+	if id <= 0 || id >= maxID {
+		return id
+	}
+	id = o.feistel(id, true)
+	for id == 0 {
+		id = o.feistel(id, true)
+	}
+	return id
+}
+
+func (o *Obfuscated) feistel(id int64, inverse bool) int64 {
+	left, right := uint32(id>>halfBits)&halfMask, uint32(id)&halfMask
+	for i := range rounds {
+		round := i
+		if inverse {
+			round = rounds - 1 - i
+			left, right = right^o.round(round, left), left
+		} else {
+			left, right = right, left^o.round(round, right)
+		}
+	}
+	return int64(left)<<halfBits | int64(right)
+}
+
+// round is the round function, a keyed hash of the round number and a half.
+func (o *Obfuscated) round(round int, half uint32) uint32 {
+	var msg [5]byte
+	msg[0] = byte(round)
+	binary.BigEndian.PutUint32(msg[1:], half)
+	mac := hmac.New(sha256.New, o.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil)) & halfMask
+}
@@ -0,0 +1,102 @@
+package shortcode_test
+
+import (
+	"math"
+	"shortlink-go/internal/shortcode"
+	"shortlink-go/pkg/base62"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscated_RoundTrip(t *testing.T) {
+	codec := shortcode.NewObfuscated("secret")
+
+	seen := make(map[int64]bool)
+	for id := int64(1); id <= 10000; id++ {
+		permuted := codec.Permute(id)
+		assert.NotZero(t, permuted)
+		assert.Less(t, permuted, int64(1)<<48)
+		assert.False(t, seen[permuted], "ID %d collides", id)
+		seen[permuted] = true
+		assert.Equal(t, id, codec.Unpermute(permuted))
+		decoded, ok := codec.Decode(codec.Encode(id))
+		assert.True(t, ok)
+		assert.Equal(t, id, decoded)
+		assert.LessOrEqual(t, len(codec.Encode(id)), 9)
+	}
+}
+
+func TestObfuscated_NotSequential(t *testing.T) {
+	codec := shortcode.NewObfuscated("secret")
+
+	// Consecutive IDs land far apart, and other secrets give other codes.
+	assert.NotEqual(t, codec.Permute(1)+1, codec.Permute(2))
+	assert.NotEqual(t, codec.Encode(1), shortcode.NewObfuscated("other").Encode(1))
+	assert.NotEqual(t, shortcode.Sequential{}.Encode(1), codec.Encode(1))
+}
+
+func TestObfuscated_OutOfRange(t *testing.T) {
+	codec := shortcode.NewObfuscated("secret")
+
+	for _, id := range []int64{-1, 0, 1 << 48, 1<<63 - 1} {
+		assert.Equal(t, id, codec.Permute(id))
+		assert.Equal(t, id, codec.Unpermute(id))
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	codecs := []shortcode.Codec{shortcode.Sequential{}, shortcode.NewObfuscated("secret")}
+	tooLarge := "z" + base62.Encode(math.MaxInt64)[1:] // Larger than math.MaxInt64.
+	for _, shortLink := range []string{"", "favicon.ico", "abc/def", "ab c", "0", "00abc", "abcdefghijkl", tooLarge, "é"} {
+		for _, codec := range codecs {
+			_, ok := codec.Decode(shortLink)
+			assert.False(t, ok, shortLink)
+		}
+	}
+	assert.Len(t, base62.Encode(math.MaxInt64), shortcode.MaxLength)
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{"a", "abc123", "favicon.ico", "0a", base62.Encode(math.MaxInt64), "zzzzzzzzzzz", "ZZZZZZZZZZZ"} {
+		f.Add(seed)
+	}
+	codecs := []shortcode.Codec{shortcode.Sequential{}, shortcode.NewObfuscated("secret")}
+	f.Fuzz(func(t *testing.T, shortLink string) {
+		for _, codec := range codecs {
+			// Only IDs decode, and every short link decodes from exactly one code.
+			id, ok := codec.Decode(shortLink)
+			if !ok {
+				continue
+			}
+			if id <= 0 {
+				t.Fatalf("%q decoded to %d", shortLink, id)
+			}
+			if encoded := codec.Encode(id); encoded != shortLink {
+				t.Fatalf("%q decoded to %d, which encodes to %q", shortLink, id, encoded)
+			}
+		}
+	})
+}
+
+func FuzzEncode(f *testing.F) {
+	for _, seed := range []int64{1, 61, 62, 1<<48 - 1, 1 << 48, math.MaxInt64} {
+		f.Add(seed)
+	}
+	codecs := []shortcode.Codec{shortcode.Sequential{}, shortcode.NewObfuscated("secret")}
+	f.Fuzz(func(t *testing.T, id int64) {
+		if id <= 0 {
+			return
+		}
+		for _, codec := range codecs {
+			shortLink := codec.Encode(id)
+			if len(shortLink) > shortcode.MaxLength {
+				t.Fatalf("%d encoded to %q, longer than %d", id, shortLink, shortcode.MaxLength)
+			}
+			decoded, ok := codec.Decode(shortLink)
+			if !ok || decoded != id {
+				t.Fatalf("%d encoded to %q, which decodes to %d, %v", id, shortLink, decoded, ok)
+			}
+		}
+	})
+}
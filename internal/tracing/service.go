@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"shortlink-go/internal/model"
+	"shortlink-go/internal/service"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var shortLinkKey = attribute.Key("shortlink.short_link")
+
+// TraceService returns a service.IService starting a span around every method
+// of next. Redis commands and database queries made by the method become its
+// children.
+func TraceService(next service.IService) service.IService {
+	return &tracedService{next: next}
+}
+
+type tracedService struct {
+	next service.IService
+}
+
+func (s *tracedService) CreateShortLink(ctx context.Context, longURL string, opts service.LinkOptions) (shortLink string, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateShortLink", trace.WithAttributes(attribute.Bool("shortlink.alias", opts.Alias != "")))
+	defer func() { End(span, err) }()
+	shortLink, err = s.next.CreateShortLink(ctx, longURL, opts)
+	span.SetAttributes(shortLinkKey.String(shortLink))
+	return shortLink, err
+}
+
+func (s *tracedService) CreateShortLinks(ctx context.Context, links []service.BatchLink) (results []service.BatchResult, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateShortLinks", trace.WithAttributes(attribute.Int("shortlink.batch_size", len(links))))
+	defer func() { End(span, err) }()
+	return s.next.CreateShortLinks(ctx, links)
+}
+
+func (s *tracedService) GetLongURL(ctx context.Context, shortLink string) (longURL string, id int64, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetLongURL", trace.WithAttributes(shortLinkKey.String(shortLink)))
+	defer func() { End(span, err) }()
+	return s.next.GetLongURL(ctx, shortLink)
+}
+
+func (s *tracedService) UpdateLongURL(ctx context.Context, shortLink, longURL string, version int64) (url *model.URL, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpdateLongURL", trace.WithAttributes(shortLinkKey.String(shortLink)))
+	defer func() { End(span, err) }()
+	return s.next.UpdateLongURL(ctx, shortLink, longURL, version)
+}
+
+func (s *tracedService) DeleteLink(ctx context.Context, shortLink string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.DeleteLink", trace.WithAttributes(shortLinkKey.String(shortLink)))
+	defer func() { End(span, err) }()
+	return s.next.DeleteLink(ctx, shortLink)
+}
+
+func (s *tracedService) SetLinkDisabled(ctx context.Context, shortLink string, disabled bool) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetLinkDisabled", trace.WithAttributes(shortLinkKey.String(shortLink), attribute.Bool("shortlink.disabled", disabled)))
+	defer func() { End(span, err) }()
+	return s.next.SetLinkDisabled(ctx, shortLink, disabled)
+}
+
+func (s *tracedService) GetLinkStats(ctx context.Context, shortLink string) (stats *model.URL, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetLinkStats", trace.WithAttributes(shortLinkKey.String(shortLink)))
+	defer func() { End(span, err) }()
+	return s.next.GetLinkStats(ctx, shortLink)
+}
+
+func (s *tracedService) GetClickTimeSeries(ctx context.Context, shortLink string, from, to time.Time, interval model.Interval, loc *time.Location) (buckets []model.ClickBucket, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetClickTimeSeries", trace.WithAttributes(shortLinkKey.String(shortLink), attribute.String("shortlink.interval", string(interval))))
+	defer func() { End(span, err) }()
+	return s.next.GetClickTimeSeries(ctx, shortLink, from, to, interval, loc)
+}
+
+func (s *tracedService) RecordClick(ctx context.Context, id int64, click model.Click, clientIP string) {
+	ctx, span := tracer.Start(ctx, "Service.RecordClick", trace.WithAttributes(attribute.Int64("shortlink.id", id)))
+	defer span.End()
+	s.next.RecordClick(ctx, id, click, clientIP)
+}
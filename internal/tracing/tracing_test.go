@@ -0,0 +1,103 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shortlink-go/internal/service"
+	"shortlink-go/internal/tracing"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	exporter  = tracetest.NewInMemoryExporter()
+	setupOnce sync.Once
+)
+
+// recordSpans installs a tracer provider exporting to exporter, which tracers
+// obtained before keep using, and clears the spans of earlier tests.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	setupOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	exporter.Reset()
+	return exporter
+}
+
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	spans := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	var handlerSpan trace.SpanContext
+	r.GET("/:shortLink", func(ctx *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "GET /:shortLink", got[0].Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", got[0].Parent.SpanID().String())
+	assert.Equal(t, trace.SpanKindServer, got[0].SpanKind)
+	assert.Equal(t, codes.Error, got[0].Status.Code)
+	assert.Equal(t, got[0].SpanContext.SpanID(), handlerSpan.SpanID())
+}
+
+func TestMiddleware_UnmatchedRoute(t *testing.T) {
+	spans := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing.Middleware())
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b", nil))
+
+	got := spans.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "GET", got[0].Name)
+	assert.Equal(t, codes.Unset, got[0].Status.Code)
+}
+
+// stubService fails every lookup and records the span it was called with.
+type stubService struct {
+	service.IService
+	span trace.SpanContext
+}
+
+func (s *stubService) GetLongURL(ctx context.Context, shortLink string) (string, int64, error) {
+	s.span = trace.SpanContextFromContext(ctx)
+	return "", 0, service.ErrShortLinkNotFound
+}
+
+func TestTraceService(t *testing.T) {
+	spans := recordSpans(t)
+	stub := &stubService{}
+	srv := tracing.TraceService(stub)
+
+	_, _, err := srv.GetLongURL(context.Background(), "abc")
+	// Errors come back as they are, as the handler compares them with ==.
+	assert.Equal(t, service.ErrShortLinkNotFound, err)
+
+	got := spans.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "Service.GetLongURL", got[0].Name)
+	assert.Equal(t, codes.Error, got[0].Status.Code)
+	assert.Equal(t, got[0].SpanContext.SpanID(), stub.span.SpanID())
+}